@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"time"
+)
+
+// Everything the program reads from and writes to. Tests replace it
+// with a scripted input, a buffer, a fixed clock and their own store.
+type App struct {
+	In    *bufio.Reader
+	Out   *Terminal
+	Now   func() time.Time
+	Store Store
+
+	// Rounding policy from config, applied when time is shown
+	Rounding Rounding
+
+	// Pomodoro intervals from config
+	Pomodoro PomodoroConfig
+}
+
+// App used by all commands, the store is opened in main
+var app = NewApp(os.Stdin, os.Stdout, time.Now, nil)
+
+func NewApp(in io.Reader, out io.Writer, now func() time.Time, store Store) *App {
+	return &App{In: bufio.NewReader(in), Out: NewTerminal(out), Now: now, Store: store, Rounding: DefaultConfig().Rounding, Pomodoro: DefaultConfig().Pomodoro}
+}
+
+// Offer to resume a timer left running, then run the commandline till quit
+// or till the input ends. A timer still running is kept for the next start.
+func RunCommandline() {
+	// Pomodoro phases are told about while waiting for input
+	if app.Out.IsTTY() {
+		go WatchPomodoro()
+	}
+
+	state := NewCommandlineState()
+	screen := RecoverTimer(state)
+	Commandline(state, screen)
+}
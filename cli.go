@@ -0,0 +1,662 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+)
+
+// Subcommand run from the command line: tm <name> [args]
+type Subcommand struct {
+	Usage string
+	Run   func(args []string) error
+}
+
+var subcommands map[string]Subcommand
+
+func init() {
+	subcommands = map[string]Subcommand{
+		"start":     {"start <activity|short|id> [--project P] [--task T] [--at TIME] [--pomodoro]", CmdStart},
+		"stop":      {"stop [--discard]", CmdStop},
+		"status":    {"status", CmdStatus},
+		"pomodoro":  {"pomodoro (turns pomodoro mode of the running timer on or off)", CmdPomodoro},
+		"switch":    {"switch <activity|short|id> [--project P] [--task T]", CmdSwitch},
+		"report":    {"report [today|week|month] [--from YYYY-MM-DD] [--to YYYY-MM-DD]", CmdReport},
+		"serve":     {"serve [--addr 127.0.0.1:7777] [--token T]", CmdServe},
+		"dashboard": {"dashboard [--addr 127.0.0.1:7777] [--token T]", CmdDashboard},
+		"import":    {"import <file> [--format csv|timewarrior|timeclock] [--activity A] [--apply]", CmdImport},
+		"archive":   {"archive <activity|short|id> [--project P]", CmdArchive},
+		"unarchive": {"unarchive <activity|short|id> [--project P]", CmdUnarchive},
+		"delete":    {"delete <activity|short|id> [--project P] --purge [--yes]", CmdDelete},
+		"rename":    {"rename <activity|short|id> [--project P] [--task T] <new name> [--short S]", CmdRename},
+		"edit":      {"edit <activity|short|id> [--project P] [--task T] <new name> [--short S]", CmdRename},
+		"undo":      {"undo [N] [--yes]", CmdUndo},
+		"redo":      {"redo [N] [--yes]", CmdRedo},
+		"log": {"log <activity|short|id> <duration> [--at TIME] | --from TIME [--to TIME]\n" +
+			"            [--project P] [--task T] [--pause M]", CmdLog},
+		"export": {"export [today|week|month] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--by session|activity|project]\n" +
+			"            [--format csv|jsonl|md] [--columns a,b,c] [--output FILE]", CmdExport},
+	}
+}
+
+// Subcommands are listed in this order
+var subcommandOrder = []string{"start", "stop", "status", "pomodoro", "switch", "log", "report", "export", "import", "archive", "unarchive", "delete", "rename", "undo", "redo", "serve", "dashboard"}
+
+// Run subcommand and return exit code
+func RunSubcommand(args []string) int {
+	command, ok := subcommands[args[0]]
+	if !ok {
+		if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			Feedback("<< Unknown command '", args[0], "' >>\n", true)
+		}
+		PrintUsage()
+		return 2
+	}
+
+	err := command.Run(args[1:])
+	if err == flag.ErrHelp {
+		return 2
+	} else if err != nil {
+		Feedback("<< [ERROR] ", err.Error(), " >>\n", true)
+		return 1
+	}
+	return 0
+}
+
+func PrintUsage() {
+	Feedback("\n<< Usage: ", "tm", " (interactive) >>\n", false)
+	for _, name := range subcommandOrder {
+		Feedback("<< Usage: ", "tm "+subcommands[name].Usage, " >>\n", false)
+	}
+}
+
+// tm start <activity> [--project P] [--task T] [--pomodoro]
+func CmdStart(args []string) error {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	project := fs.String("project", "", "project of the activity, name or id")
+	task := fs.String("task", "", "task of the project, name or id")
+	at := fs.String("at", "", "started at HH:MM, dd.mm.yyyy HH:MM or 20m ago")
+	pomodoro := fs.Bool("pomodoro", false, "work and break intervals, the first one starts now")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tm " + subcommands["start"].Usage)
+	}
+
+	// Timer started a while ago
+	now := app.Now()
+	start := now
+	if *at != "" {
+		start, err = ParseLogTime(*at, now)
+		if err != nil {
+			return err
+		}
+		if start.After(now) {
+			return errors.New("start is in the future")
+		}
+	}
+
+	err = WithTimerLock(func() error {
+		return StartTimer(positional[0], *project, *task, start)
+	})
+	if err != nil || !*pomodoro {
+		return err
+	}
+
+	timer, err := StartPomodoro(now)
+	if err != nil {
+		return err
+	}
+	PrintPomodoro(timer)
+	return nil
+}
+
+// tm pomodoro
+func CmdPomodoro(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: tm " + subcommands["pomodoro"].Usage)
+	}
+
+	timer, err := TogglePomodoro(app.Now())
+	if err != nil {
+		return err
+	}
+	PrintPomodoro(timer)
+	return nil
+}
+
+// Tell phase of the timer and when it ends
+func PrintPomodoro(timer Timer) {
+	if timer.Pomodoro == nil {
+		Feedback("<< ", "Pomodoro mode off", " >>\n", false)
+		return
+	}
+	Feedback("<< Pomodoro: ", PomodoroStatus(*timer.Pomodoro), " >>\n", false)
+}
+
+// tm log <activity> <duration> [--at TIME] | --from TIME [--to TIME]
+func CmdLog(args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	project := fs.String("project", "", "project of the activity, name or id")
+	task := fs.String("task", "", "task of the project, name or id")
+	at := fs.String("at", "", "start of the duration, default: it ends now")
+	from := fs.String("from", "", "start as HH:MM, dd.mm.yyyy HH:MM or 20m ago")
+	to := fs.String("to", "", "end like --from, default: now")
+	pause := fs.Int("pause", 0, "minutes of pause")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	usage := errors.New("usage: tm " + subcommands["log"].Usage)
+
+	now := app.Now()
+	var start, end time.Time
+
+	switch {
+	case len(positional) == 2 && *from == "" && *to == "":
+		duration, err := ParseLogDuration(positional[1])
+		if err != nil {
+			return err
+		}
+
+		// Duration ends now unless its start is given
+		start, end = now.Add(-duration), now
+		if *at != "" {
+			start, err = ParseLogTime(*at, now)
+			if err != nil {
+				return err
+			}
+			end = start.Add(duration)
+		}
+
+	case len(positional) == 1 && *from != "" && *at == "":
+		start, err = ParseLogTime(*from, now)
+		if err != nil {
+			return err
+		}
+		end = now
+		if *to != "" {
+			end, err = ParseLogEnd(*to, start, now)
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		return usage
+	}
+
+	return WithTimerLock(func() error {
+		session, err := LogSession(positional[0], *project, *task, start, end, time.Duration(*pause)*time.Minute)
+		if err != nil {
+			return err
+		}
+		PrintLoggedSession(session)
+		return nil
+	})
+}
+
+// tm stop [--discard]
+func CmdStop(args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ContinueOnError)
+	discard := fs.Bool("discard", false, "do not save the time")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: tm " + subcommands["stop"].Usage)
+	}
+
+	return WithTimerLock(func() error {
+		return StopTimer(*discard, app.Now())
+	})
+}
+
+// tm status
+func CmdStatus(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: tm " + subcommands["status"].Usage)
+	}
+
+	// Breaks that passed are pause time
+	timer, _, err := AdvancePomodoro(app.Now())
+	if err != nil {
+		return err
+	}
+	if timer == nil {
+		Feedback("<< ", "No timer running", " >>\n", false)
+		return nil
+	}
+
+	Feedback("<< [", timer.Activity, "]", false)
+	if timer.Project != "" {
+		Feedback(" Project: ", timer.Project, "", false)
+	}
+	if timer.Task != "" {
+		Feedback(" Task: ", timer.Task, "", false)
+	}
+	Feedback(" Elapsed Time: ", TimerElapsed(*timer, app.Now()).Round(time.Second), "", false)
+	Feedback(" since start ", timer.Start.Format("15:04:05"), " >>\n", false)
+	if timer.Pomodoro != nil {
+		PrintPomodoro(*timer)
+	}
+	return nil
+}
+
+// tm switch <activity> [--project P] [--task T]
+func CmdSwitch(args []string) error {
+	fs := flag.NewFlagSet("switch", flag.ContinueOnError)
+	project := fs.String("project", "", "project of the activity, name or id")
+	task := fs.String("task", "", "task of the project, name or id")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tm " + subcommands["switch"].Usage)
+	}
+
+	// Check new activity before the running one is stopped
+	_, _, _, err = ResolveTimerTarget(OpenAndGetDataFromJson(), positional[0], *project, *task)
+	if err != nil {
+		return err
+	}
+
+	return WithTimerLock(func() error {
+		// Stop and save running timer, the new one starts where the old one ends
+		now := app.Now()
+		running, err := LoadTimer()
+		if err != nil {
+			return err
+		}
+		if running != nil {
+			err = StopTimer(false, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		return StartTimer(positional[0], *project, *task, now)
+	})
+}
+
+// tm archive <activity> [--project P]
+func CmdArchive(args []string) error {
+	return archiveCommand("archive", args, true)
+}
+
+// tm unarchive <activity> [--project P]
+func CmdUnarchive(args []string) error {
+	return archiveCommand("unarchive", args, false)
+}
+
+// Hide or restore activity, or only its project with --project
+func archiveCommand(name string, args []string, archived bool) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	project := fs.String("project", "", "project of the activity, name or id")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tm " + subcommands[name].Usage)
+	}
+
+	activity, ok := FindActivity(OpenAndGetDataFromJson(), positional[0])
+	if !ok {
+		return fmt.Errorf("no such activity '%s'", positional[0])
+	}
+
+	if *project != "" {
+		p, ok := FindProjectNamed(*project, activity)
+		if !ok {
+			return fmt.Errorf("no such project '%s' in '%s'", *project, activity.Activity)
+		}
+		err = app.Store.ArchiveProject(p.Id, archived)
+		if err != nil {
+			return err
+		}
+		Feedback("<< Project '", p.Name, "' "+name+"d >>\n", false)
+		return nil
+	}
+
+	if archived {
+		running, err := TimerRunsFor(activity.Id)
+		if err != nil {
+			return err
+		}
+		if running {
+			return fmt.Errorf("'%s' is running, stop it first", activity.Activity)
+		}
+	}
+
+	err = app.Store.ArchiveActivity(activity.Id, archived)
+	if err != nil {
+		return err
+	}
+	Feedback("<< '", activity.Activity, "' "+name+"d >>\n", false)
+	return nil
+}
+
+// tm delete <activity> [--project P] --purge [--yes]
+func CmdDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	project := fs.String("project", "", "project of the activity, name or id")
+	purge := fs.Bool("purge", false, "delete for good with all saved time")
+	yes := fs.Bool("yes", false, "do not ask before deleting")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tm " + subcommands["delete"].Usage)
+	}
+	if !*purge {
+		return errors.New("delete removes all saved time for good, add --purge or use tm archive")
+	}
+
+	activity, ok := FindActivity(OpenAndGetDataFromJson(), positional[0])
+	if !ok {
+		return fmt.Errorf("no such activity '%s'", positional[0])
+	}
+
+	if *project != "" {
+		p, ok := FindProjectNamed(*project, activity)
+		if !ok {
+			return fmt.Errorf("no such project '%s' in '%s'", *project, activity.Activity)
+		}
+		if !*yes && !PurgeCheckQuestion(p.Name) {
+			Feedback("<< ", "Cancelled", " >>\n", true)
+			return nil
+		}
+		err = app.Store.DeleteProject(p.Id)
+		if err != nil {
+			return err
+		}
+		Feedback("<< Project '", p.Name, "' has been deleted! >>\n", true)
+		return nil
+	}
+
+	running, err := TimerRunsFor(activity.Id)
+	if err != nil {
+		return err
+	}
+	if running {
+		return fmt.Errorf("'%s' is running, stop it first", activity.Activity)
+	}
+
+	if !*yes && !PurgeCheckQuestion(activity.Activity) {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return nil
+	}
+	err = app.Store.DeleteActivity(activity.Id)
+	if err != nil {
+		return err
+	}
+	Feedback("<< '", activity.Activity, "' has been deleted! >>\n", true)
+	return nil
+}
+
+// tm rename <activity> [--project P] [--task T] <new name> [--short S]
+func CmdRename(args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	project := fs.String("project", "", "rename this project of the activity, name or id")
+	task := fs.String("task", "", "rename this task of the project, name or id")
+	short := fs.String("short", "", "new short name of the activity")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: tm " + subcommands["rename"].Usage)
+	}
+	name := positional[1]
+
+	data := OpenAndGetDataFromJson()
+	activity, ok := FindActivity(data, positional[0])
+	if !ok {
+		return fmt.Errorf("no such activity '%s'", positional[0])
+	}
+
+	if *project == "" {
+		if *task != "" {
+			return errors.New("--task needs --project")
+		}
+		if *short == "" {
+			*short = activity.Short
+		}
+		err = CheckActivityRenames(activity, name, *short, data)
+		if err != nil {
+			return err
+		}
+
+		err = RenameActivity(activity, name, *short)
+		if err != nil {
+			return err
+		}
+		Feedback("<< '", activity.Activity, "' renamed to '"+name+"' ("+*short+") >>\n", false)
+		return nil
+	}
+
+	if *short != "" {
+		return errors.New("--short only renames activities")
+	}
+	p, ok := FindProjectNamed(*project, activity)
+	if !ok {
+		return fmt.Errorf("no such project '%s' in '%s'", *project, activity.Activity)
+	}
+
+	if *task == "" {
+		err = CheckProjectRename(p, name, data)
+		if err != nil {
+			return err
+		}
+
+		err = RenameProject(activity, p, name)
+		if err != nil {
+			return err
+		}
+		Feedback("<< Project '", p.Name, "' renamed to '"+name+"' >>\n", false)
+		return nil
+	}
+
+	t, ok := FindTaskNamed(*task, p)
+	if !ok {
+		return fmt.Errorf("no such task '%s' in project '%s'", *task, p.Name)
+	}
+	err = CheckTaskRename(t, p, name)
+	if err != nil {
+		return err
+	}
+
+	err = RenameTask(activity, p, t, name)
+	if err != nil {
+		return err
+	}
+	Feedback("<< Task '", t.Name, "' renamed to '"+name+"' >>\n", false)
+	return nil
+}
+
+// tm undo [N] [--yes]
+func CmdUndo(args []string) error {
+	return replayCommand("undo", args, true)
+}
+
+// tm redo [N] [--yes]
+func CmdRedo(args []string) error {
+	return replayCommand("redo", args, false)
+}
+
+// Show last N operations of the journal, ask and undo or redo them
+func replayCommand(name string, args []string, undo bool) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "do not ask first")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: tm " + subcommands[name].Usage)
+	}
+
+	count := 1
+	if len(positional) == 1 {
+		count, err = strconv.Atoi(positional[0])
+		if err != nil || count < 1 {
+			return fmt.Errorf("N must be a positive number, not '%s'", positional[0])
+		}
+	}
+
+	journal, err := LoadJournal()
+	if err != nil {
+		return err
+	}
+	entries := journal.Redo
+	if undo {
+		entries = journal.Undo
+	}
+
+	// Show what is going to happen before doing it
+	pending := LastEntries(entries, count)
+	if len(pending) == 0 {
+		Feedback("<< ", "Nothing to "+name, " >>\n", true)
+		return nil
+	}
+	PrintJournalEntries(pending)
+
+	if !*yes && !AskYes(fmt.Sprint(name, " ", len(pending), " operation(s)?")) {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return nil
+	}
+
+	done, err := ReplayJournal(len(pending), undo)
+	for _, entry := range done {
+		Feedback("<< "+name+": ", entry.Description, " >>\n", false)
+	}
+	return err
+}
+
+// Start timer and tell the user
+func StartTimer(name string, project string, task string, start time.Time) error {
+	timer, err := BeginTimer(name, project, task, start)
+	if err == ErrTimerRunning {
+		return fmt.Errorf("'%s' is already running, use stop or switch", timer.Activity)
+	} else if err != nil {
+		return err
+	}
+
+	Feedback("<< Starting ", timer.Activity, "", false)
+	if timer.Project != "" {
+		Feedback(" / ", timer.Project, "", false)
+	}
+	if timer.Task != "" {
+		Feedback(" / ", timer.Task, "", false)
+	}
+	Feedback(" at ", start.Format("02.01.2006 15:04:05"), " >>\n", false)
+	return nil
+}
+
+// Stop timer and tell the user
+func StopTimer(discard bool, now time.Time) error {
+	timer, session, err := EndTimer(discard, now)
+	if err != nil {
+		return err
+	}
+
+	if session == nil && !discard {
+		Feedback("<< [", timer.Activity, "] stopped, no time worked to save >>\n", false)
+		return nil
+	}
+	if session == nil {
+		Feedback("<< ", "LAST TIME NOT SAVED", " >>\n", true)
+		return nil
+	}
+
+	Feedback("<< [", timer.Activity, "]", false)
+	Feedback(" You have spent ", TimerElapsed(timer, now).Round(time.Second), "", false)
+	Feedback(" ", "LAST TIME HAS BEEN SAVED", " >>\n", false)
+	return nil
+}
+
+// Find activity by name, short name or id and check that project and task exist in it.
+// Project and task are found by name or id, their names are returned.
+func ResolveTimerTarget(data []JsonData, name string, project string, task string) (JsonData, string, string, error) {
+	activity, ok := FindActivity(data, name)
+	if !ok {
+		return activity, "", "", fmt.Errorf("no such activity '%s'", name)
+	}
+	if activity.Archived {
+		return activity, "", "", fmt.Errorf("'%s' is archived, use tm unarchive", activity.Activity)
+	}
+
+	if project == "" {
+		if task != "" {
+			return activity, "", "", errors.New("--task needs --project")
+		}
+		return activity, "", "", nil
+	}
+
+	p, ok := FindProjectNamed(project, activity)
+	if !ok {
+		return activity, "", "", fmt.Errorf("no such project '%s' in '%s'", project, activity.Activity)
+	}
+	if p.Archived {
+		return activity, "", "", fmt.Errorf("project '%s' is archived, use tm unarchive", p.Name)
+	}
+	if task == "" {
+		return activity, p.Name, "", nil
+	}
+
+	t, ok := FindTaskNamed(task, p)
+	if ok {
+		return activity, p.Name, t.Name, nil
+	}
+	return activity, "", "", fmt.Errorf("no such task '%s' in project '%s'", task, p.Name)
+}
+
+// Find activity the same way the commandline does: by name, short name or id
+func FindActivity(data []JsonData, name string) (JsonData, bool) {
+	for _, value := range data {
+		if value.Activity == name || value.Short == name || fmt.Sprint(value.Id) == name {
+			return value, true
+		}
+	}
+	return JsonData{}, false
+}
+
+// Parse flags that may come before or after positional arguments
+func ParseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(ioutil.Discard)
+
+	positional := []string{}
+	for {
+		err := fs.Parse(args)
+		if err == flag.ErrHelp {
+			Feedback("<< Usage: ", "tm "+subcommands[fs.Name()].Usage, " >>\n", false)
+			return nil, err
+		} else if err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+var configFilename = "data/config.json"
+
+// Settings from data/config.json
+type Config struct {
+	// Time left is counted till this on days without working hours
+	DayEnd string `json:"day_end"`
+
+	// Working hours by weekday: monday, tuesday, ... sunday
+	WorkingHours map[string]WorkingHours `json:"working_hours"`
+
+	// Saved time is kept to the second, it is rounded when shown, reported or exported
+	Rounding Rounding `json:"rounding"`
+
+	// Work and break intervals of pomodoro mode
+	Pomodoro PomodoroConfig `json:"pomodoro"`
+}
+
+// Rounding policy, the default rounds totals to the nearest minute
+type Rounding struct {
+	// Round to this many minutes: 1, 5, 6, 15 ...
+	Minutes int `json:"minutes"`
+
+	// nearest, up or down
+	Mode string `json:"mode"`
+
+	// total rounds only sums, session rounds every session before it is added (billing)
+	Per string `json:"per"`
+}
+
+// Working hours of one weekday, end before start means the shift ends the next day
+type WorkingHours struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	Off   bool   `json:"off,omitempty"`
+}
+
+// Time left, time logged and capacity of the current workday, in minutes
+type DayStatus struct {
+	// Working hours are set for today
+	Working bool
+	Off     bool
+
+	// End of the workday or day end
+	End      time.Time
+	Left     int
+	Logged   int
+	Capacity int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DayEnd:       "22:00",
+		WorkingHours: map[string]WorkingHours{},
+		Rounding:     Rounding{Minutes: 1, Mode: "nearest", Per: "total"},
+		Pomodoro:     PomodoroConfig{Work: 25, Break: 5, LongBreak: 15, LongBreakEvery: 4},
+	}
+}
+
+// Read config, defaults are used when the file does not exist
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+
+	file, err := ioutil.ReadFile(configFilename)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(file, &config)
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("%s: %w", configFilename, err)
+	}
+
+	err = config.Check()
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("%s: %w", configFilename, err)
+	}
+	return config, nil
+}
+
+// Check weekday names, HH:MM times, the rounding policy and pomodoro intervals
+func (c Config) Check() error {
+	_, err := ParseClock(c.DayEnd)
+	if err != nil {
+		return fmt.Errorf("day_end: %w", err)
+	}
+
+	err = c.Rounding.Check()
+	if err != nil {
+		return fmt.Errorf("rounding: %w", err)
+	}
+
+	err = c.Pomodoro.Check()
+	if err != nil {
+		return fmt.Errorf("pomodoro: %w", err)
+	}
+
+	for day, hours := range c.WorkingHours {
+		if _, ok := ParseWeekday(day); !ok {
+			return fmt.Errorf("unknown weekday '%s', use monday ... sunday", day)
+		}
+		if hours.Off {
+			continue
+		}
+
+		for _, clock := range []string{hours.Start, hours.End} {
+			_, err := ParseClock(clock)
+			if err != nil {
+				return fmt.Errorf("%s: %w", day, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Working hours of weekday, ok is false if none are set
+func (c Config) Hours(day time.Weekday) (WorkingHours, bool) {
+	for name, hours := range c.WorkingHours {
+		if weekday, _ := ParseWeekday(name); weekday == day {
+			return hours, true
+		}
+	}
+	return WorkingHours{}, false
+}
+
+// Start and end of the shift that began on day
+func (c Config) Shift(day time.Time) (time.Time, time.Time, bool) {
+	hours, ok := c.Hours(day.Weekday())
+	if !ok || hours.Off {
+		return time.Time{}, time.Time{}, false
+	}
+
+	startClock, _ := ParseClock(hours.Start)
+	endClock, _ := ParseClock(hours.End)
+
+	// Clock times on the calendar day, adding them to midnight is an hour off on DST changes
+	at := func(clock time.Duration) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, day.Location())
+	}
+
+	start := at(startClock)
+	end := at(endClock)
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
+
+// Status of the workday at now. Logged time counts sessions since midnight,
+// or since the start of a night shift that began yesterday, and the running timer.
+func (c Config) DayStatus(data []JsonData, timer *Timer, now time.Time) DayStatus {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	status := DayStatus{}
+	from := today
+
+	start, end, working := c.Shift(today)
+	hours, hasHours := c.Hours(today.Weekday())
+
+	// Night shift from yesterday is still running
+	if yesterdayStart, yesterdayEnd, ok := c.Shift(today.AddDate(0, 0, -1)); ok && now.Before(yesterdayEnd) {
+		start, end, working, from = yesterdayStart, yesterdayEnd, true, yesterdayStart
+	}
+
+	// Add up to the second, round once
+	logged := time.Duration(0)
+	for _, session := range SessionsInRange(data, from, now.Add(time.Minute)) {
+		logged += c.Rounding.Counted(SessionDuration(session))
+	}
+	if timer != nil {
+		logged += TimerElapsed(*timer, now)
+	}
+	status.Logged = c.Rounding.Round(logged)
+
+	switch {
+	case working:
+		status.Working = true
+		status.End = end
+		status.Capacity = Max(int(end.Sub(start).Minutes())-status.Logged, 0)
+		if now.Before(start) {
+			status.Left = int(end.Sub(start).Minutes())
+		} else {
+			status.Left = Max(int(end.Sub(now).Minutes()), 0)
+		}
+	case hasHours && hours.Off:
+		status.Off = true
+	default:
+		// Till day end, tomorrow's if it has passed
+		dayEnd, _ := ParseClock(c.DayEnd)
+		status.End = today.Add(dayEnd)
+		if !status.End.After(now) {
+			status.End = status.End.AddDate(0, 0, 1)
+		}
+		status.Left = int(status.End.Sub(now).Minutes())
+	}
+	return status
+}
+
+// HH:MM as time since midnight
+func ParseClock(clock string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("time '%s' must be HH:MM", clock)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+func ParseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return time.Sunday, false
+}
+
+func Max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Step of at least a minute, known mode and per
+func (r Rounding) Check() error {
+	if r.Minutes < 1 {
+		return fmt.Errorf("minutes must be 1 or more, not %d", r.Minutes)
+	}
+	if r.Mode != "nearest" && r.Mode != "up" && r.Mode != "down" {
+		return fmt.Errorf("unknown mode '%s', use nearest, up or down", r.Mode)
+	}
+	if r.Per != "total" && r.Per != "session" {
+		return fmt.Errorf("unknown per '%s', use total or session", r.Per)
+	}
+	return nil
+}
+
+// Whole minutes of duration, rounded to the step in the direction of mode
+func (r Rounding) Round(duration time.Duration) int {
+	step := time.Duration(r.Minutes) * time.Minute
+	steps := duration / step
+	rest := duration % step
+
+	switch r.Mode {
+	case "up":
+		if rest > 0 {
+			steps++
+		}
+	case "down":
+		if rest < 0 {
+			steps--
+		}
+	default:
+		steps = duration.Round(step) / step
+	}
+	return int(steps) * r.Minutes
+}
+
+// Time one session adds to a sum: rounded already with per session, exact otherwise
+func (r Rounding) Counted(duration time.Duration) time.Duration {
+	if r.Per == "session" {
+		return time.Duration(r.Round(duration)) * time.Minute
+	}
+	return duration
+}
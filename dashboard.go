@@ -0,0 +1,136 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Html, css and js of the dashboard, built into the binary
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// Time of one day in the dashboard chart
+type DashboardDay struct {
+	Date       string         `json:"date"`
+	Minutes    int            `json:"minutes"`
+	Activities map[string]int `json:"activities"`
+
+	// Exact time by activity, minutes are rounded from it
+	seconds map[string]int
+}
+
+// Everything the dashboard shows besides the timer
+type Dashboard struct {
+	Days    []DashboardDay `json:"days"`
+	Report  Report         `json:"report"`
+	Totals  []ReportItem   `json:"totals"`
+	Minutes int            `json:"minutes"`
+}
+
+// tm dashboard [--addr 127.0.0.1:7777] [--token T]
+func CmdDashboard(args []string) error {
+	flags := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:7777", "address to listen on")
+	token := flags.String("token", os.Getenv("TM_TOKEN"), "bearer token for the api (default $TM_TOKEN)")
+
+	positional, err := ParseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: tm " + subcommands["dashboard"].Usage)
+	}
+
+	handler, err := NewDashboardHandler(*token)
+	if err != nil {
+		return err
+	}
+
+	Feedback("<< Dashboard on ", "http://"+*addr+"/", " >>\n", false)
+	return http.ListenAndServe(*addr, handler)
+}
+
+// Static files on / and the api on /api/
+func NewDashboardHandler(token string) (http.Handler, error) {
+	static, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", NewApiHandler(token))
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	return mux, nil
+}
+
+// Chart, report and totals for the last ?days=30 days
+func ApiDashboard(r *http.Request) (interface{}, error) {
+	days := 30
+	if r.URL.Query().Get("days") != "" {
+		var err error
+		days, err = strconv.Atoi(r.URL.Query().Get("days"))
+		if err != nil || days < 1 || days > 366 {
+			return nil, BadRequest(errors.New("days must be a number from 1 to 366"))
+		}
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return nil, err
+	}
+	return BuildDashboard(data, days, app.Now()), nil
+}
+
+func BuildDashboard(data []JsonData, days int, now time.Time) Dashboard {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from, to := today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1)
+
+	dashboard := Dashboard{Report: BuildReport(data, from, to), Totals: []ReportItem{}}
+
+	// One entry for every day, also days without time
+	index := map[string]int{}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		index[day.Format("2006-01-02")] = len(dashboard.Days)
+		dashboard.Days = append(dashboard.Days, DashboardDay{Date: day.Format("2006-01-02"), Activities: map[string]int{}, seconds: map[string]int{}})
+	}
+
+	total := 0
+	for _, activity := range data {
+		for _, session := range SessionsInRange([]JsonData{activity}, from, to) {
+			// Day of the start where the dashboard is looked at
+			at, ok := index[session.Start.In(now.Location()).Format("2006-01-02")]
+			if !ok {
+				continue
+			}
+			day := &dashboard.Days[at]
+			day.seconds[activity.Activity] += Seconds(app.Rounding.Counted(SessionDuration(session)))
+		}
+
+		// All time, legacy time included
+		seconds := Seconds(ActivityDuration(activity))
+		total += seconds
+		dashboard.Totals = append(dashboard.Totals, ReportItem{Name: activity.Activity, Minutes: RoundSeconds(seconds), Seconds: seconds})
+	}
+
+	// Round sums of the day, not every session
+	for i := range dashboard.Days {
+		day := &dashboard.Days[i]
+		daySeconds := 0
+		for name, seconds := range day.seconds {
+			day.Activities[name] = RoundSeconds(seconds)
+			daySeconds += seconds
+		}
+		day.Minutes = RoundSeconds(daySeconds)
+	}
+
+	dashboard.Minutes = RoundSeconds(total)
+	SortReportItems(dashboard.Totals)
+	return dashboard
+}
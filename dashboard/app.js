@@ -0,0 +1,373 @@
+"use strict";
+
+// Colors of activities in the chart, same activity keeps its color
+const colors = ["#7ec8e3", "#f0a35e", "#9ad27b", "#d48fd8", "#f07070", "#e6d36a", "#6fd6c0", "#a0a8f0"];
+
+let activities = [];
+let timer = { running: false };
+let timerLoadedAt = Date.now();
+
+// Call the api, asks for the token when the server wants one
+async function api(method, path, body) {
+	const headers = { "Content-Type": "application/json" };
+	const token = localStorage.getItem("tm-token");
+	if (token) {
+		headers["Authorization"] = "Bearer " + token;
+	}
+
+	const response = await fetch("/api/" + path, {
+		method: method,
+		headers: headers,
+		body: body === undefined ? undefined : JSON.stringify(body),
+	});
+
+	if (response.status === 401) {
+		const given = prompt("Token for the TimeManagement api:");
+		if (given) {
+			localStorage.setItem("tm-token", given);
+			return api(method, path, body);
+		}
+	}
+	if (response.status === 204) {
+		return null;
+	}
+
+	const result = await response.json();
+	if (!response.ok) {
+		throw new Error(result.error || response.statusText);
+	}
+	return result;
+}
+
+// Show error in the header until the next action works
+async function run(action) {
+	try {
+		await action();
+		document.getElementById("error").textContent = "";
+	} catch (err) {
+		document.getElementById("error").textContent = err.message;
+	}
+}
+
+function element(tag, text, className) {
+	const el = document.createElement(tag);
+	if (text !== undefined) {
+		el.textContent = text;
+	}
+	if (className) {
+		el.className = className;
+	}
+	return el;
+}
+
+// Minutes as 1h 05m
+function formatMinutes(total) {
+	const sign = total < 0 ? "-" : "";
+	total = Math.abs(total);
+	return sign + Math.floor(total / 60) + "h " + String(total % 60).padStart(2, "0") + "m";
+}
+
+function formatSeconds(total) {
+	total = Math.max(0, total);
+	const hours = Math.floor(total / 3600);
+	const minutes = Math.floor(total / 60) % 60;
+	const seconds = total % 60;
+	return hours + ":" + String(minutes).padStart(2, "0") + ":" + String(seconds).padStart(2, "0");
+}
+
+function percent(part, total) {
+	return total === 0 ? "0.0%" : (part * 100 / total).toFixed(1) + "%";
+}
+
+// Archived activities and projects are hidden, their time still counts in the report
+function visible(items) {
+	return items.filter(item => !item.archived);
+}
+
+function colorOf(name) {
+	const index = activities.findIndex(activity => activity.activity === name);
+	return colors[(index < 0 ? 0 : index) % colors.length];
+}
+
+/*<=================================================== Timer ===================================================>*/
+
+async function loadTimer() {
+	timer = await api("GET", "timer");
+	timerLoadedAt = Date.now();
+	renderTimer();
+}
+
+function renderTimer() {
+	const running = document.getElementById("timer-running");
+	running.hidden = !timer.running;
+	document.getElementById("timer-start").hidden = timer.running;
+	if (!timer.running) {
+		return;
+	}
+
+	const t = timer.timer;
+	document.getElementById("timer-name").textContent =
+		[t.activity, t.project, t.task].filter(Boolean).join(" / ") + (timer.paused ? " (paused)" : "");
+	document.getElementById("pause").hidden = timer.paused;
+	document.getElementById("resume").hidden = !timer.paused;
+	running.classList.toggle("paused", timer.paused);
+	tick();
+}
+
+// Count up locally between reloads
+function tick() {
+	if (!timer.running) {
+		return;
+	}
+	let elapsed = timer.elapsed;
+	if (!timer.paused) {
+		elapsed += Math.floor((Date.now() - timerLoadedAt) / 1000);
+	}
+	document.getElementById("timer-elapsed").textContent = formatSeconds(elapsed);
+}
+
+function timerAction(action, body) {
+	return () => run(async () => {
+		await api("POST", "timer/" + action, body);
+		await loadAll();
+	});
+}
+
+/*<=================================================== Activities ===================================================>*/
+
+// Fill activity, project and task selects, projects and tasks follow the selection above them
+function bindSelects(prefix) {
+	const activitySelect = document.getElementById(prefix + "-activity");
+	const projectSelect = document.getElementById(prefix + "-project");
+	const taskSelect = document.getElementById(prefix + "-task");
+
+	function fill(select, names, empty) {
+		const selected = select.value;
+		select.replaceChildren();
+		if (empty) {
+			select.append(new Option(empty, ""));
+		}
+		for (const [value, name] of names) {
+			select.append(new Option(name, value));
+		}
+		if ([...select.options].some(option => option.value === selected)) {
+			select.value = selected;
+		}
+	}
+
+	function selectedActivity() {
+		return activities.find(activity => String(activity.id) === activitySelect.value);
+	}
+
+	function selectedProject() {
+		const activity = selectedActivity();
+		return activity && activity.projects.find(project => project.name === projectSelect.value);
+	}
+
+	function fillTasks() {
+		const project = selectedProject();
+		// Done tasks can't be started, time can still be logged for them
+		const tasks = project ? project.tasks.filter(task => prefix !== "start" || task.status !== "done") : [];
+		fill(taskSelect, tasks.map(task => [task.name, task.name]), "no task");
+	}
+
+	function fillProjects() {
+		const activity = selectedActivity();
+		fill(projectSelect, activity ? visible(activity.projects).map(project => [project.name, project.name]) : [], "no project");
+		fillTasks();
+	}
+
+	activitySelect.onchange = fillProjects;
+	projectSelect.onchange = fillTasks;
+
+	return function refresh() {
+		fill(activitySelect, visible(activities).map(activity => [String(activity.id), activity.activity]));
+		fillProjects();
+	};
+}
+
+const refreshStartSelects = bindSelects("start");
+const refreshLogSelects = bindSelects("log");
+
+function renderActivities() {
+	const list = document.getElementById("activities");
+	list.replaceChildren();
+	if (visible(activities).length === 0) {
+		list.append(element("p", "No activities yet, add one with tm", "empty"));
+		return;
+	}
+
+	for (const activity of visible(activities)) {
+		const title = element("h3", activity.activity + " ");
+		title.append(element("small", "[" + activity.short + "]"));
+		list.append(title);
+
+		const projects = element("ul");
+		for (const project of visible(activity.projects)) {
+			const item = element("li", project.name);
+			if (project.tasks.length > 0) {
+				const tasks = element("ul");
+				for (const task of project.tasks) {
+					tasks.append(element("li", task.name, task.status === "done" ? "done" : ""));
+				}
+				item.append(tasks);
+			}
+			projects.append(item);
+		}
+		if (visible(activity.projects).length === 0) {
+			projects.append(element("li", "no projects", "empty"));
+		}
+		list.append(projects);
+	}
+}
+
+/*<=================================================== Report ===================================================>*/
+
+function row(item, total, level) {
+	const tr = element("tr", undefined, "level" + level);
+	tr.append(element("td", item.name), element("td", formatMinutes(item.minutes), "time"), element("td", percent(item.minutes, total), "percent"));
+	return tr;
+}
+
+function renderReport(dashboard) {
+	const report = document.getElementById("report");
+	report.replaceChildren();
+	for (const activity of dashboard.report.activities || []) {
+		report.append(row(activity, dashboard.report.minutes, 0));
+		for (const project of activity.items || []) {
+			report.append(row(project, dashboard.report.minutes, 1));
+			for (const task of project.items || []) {
+				report.append(row(task, dashboard.report.minutes, 2));
+			}
+		}
+	}
+	if (report.children.length === 0) {
+		report.append(element("tr", "No time saved in this period", "empty"));
+	}
+
+	const totals = document.getElementById("totals");
+	totals.replaceChildren();
+	for (const activity of dashboard.totals) {
+		totals.append(row(activity, dashboard.minutes, 0));
+	}
+	document.getElementById("totals-total").textContent = formatMinutes(dashboard.minutes);
+}
+
+// Stacked bars per day, one color per activity
+function renderChart(dashboard) {
+	const svg = document.getElementById("chart");
+	const ns = "http://www.w3.org/2000/svg";
+	svg.replaceChildren();
+
+	const width = 900, height = 240, bottom = 20;
+	const max = Math.max(60, ...dashboard.days.map(day => day.minutes));
+	const slot = width / dashboard.days.length;
+
+	function add(tag, attributes, text) {
+		const el = document.createElementNS(ns, tag);
+		for (const [key, value] of Object.entries(attributes)) {
+			el.setAttribute(key, value);
+		}
+		if (text !== undefined) {
+			el.textContent = text;
+		}
+		svg.append(el);
+		return el;
+	}
+
+	dashboard.days.forEach((day, i) => {
+		let y = height - bottom;
+		for (const activity of dashboard.totals) {
+			const minutes = day.activities[activity.name] || 0;
+			if (minutes <= 0) {
+				continue;
+			}
+			const h = minutes / max * (height - bottom - 10);
+			y -= h;
+			const bar = add("rect", { x: i * slot + 2, y: y, width: slot - 4, height: h, fill: colorOf(activity.name) });
+			bar.append(document.createElementNS(ns, "title"));
+			bar.firstChild.textContent = day.date + " " + activity.name + " " + formatMinutes(minutes);
+		}
+
+		// Label every monday and the last day
+		const date = new Date(day.date + "T00:00:00");
+		if (date.getDay() === 1 || i === dashboard.days.length - 1) {
+			add("text", { x: i * slot + 2, y: height - 5 }, day.date.slice(8) + "." + day.date.slice(5, 7) + ".");
+		}
+	});
+
+	const legend = document.getElementById("legend");
+	legend.replaceChildren();
+	for (const activity of dashboard.report.activities || []) {
+		const span = element("span", activity.name);
+		const swatch = element("i");
+		swatch.style.background = colorOf(activity.name);
+		span.prepend(swatch);
+		legend.append(span);
+	}
+	document.getElementById("chart-total").textContent = formatMinutes(dashboard.report.minutes);
+}
+
+/*<=================================================== Load ===================================================>*/
+
+async function loadAll() {
+	const [data, dashboard] = await Promise.all([api("GET", "activities"), api("GET", "dashboard?days=30")]);
+	activities = data || [];
+	refreshStartSelects();
+	refreshLogSelects();
+	renderActivities();
+	renderReport(dashboard);
+	renderChart(dashboard);
+	await loadTimer();
+}
+
+document.getElementById("pause").onclick = timerAction("pause");
+document.getElementById("resume").onclick = timerAction("resume");
+document.getElementById("stop").onclick = timerAction("stop", { discard: false });
+document.getElementById("discard").onclick = () => {
+	if (confirm("Throw the running time away?")) {
+		timerAction("stop", { discard: true })();
+	}
+};
+
+document.getElementById("timer-start").onsubmit = event => {
+	event.preventDefault();
+	run(async () => {
+		await api("POST", "timer/start", {
+			activity: document.getElementById("start-activity").value,
+			project: document.getElementById("start-project").value,
+			task: document.getElementById("start-task").value,
+		});
+		await loadAll();
+	});
+};
+
+document.getElementById("log-form").onsubmit = event => {
+	event.preventDefault();
+	run(async () => {
+		const date = document.getElementById("log-date").value;
+		const start = new Date(date + "T" + document.getElementById("log-start").value);
+		const end = new Date(date + "T" + document.getElementById("log-end").value);
+		// Session over midnight ends the next day
+		if (end <= start) {
+			end.setDate(end.getDate() + 1);
+		}
+
+		await api("POST", "sessions", {
+			activity_id: Number(document.getElementById("log-activity").value),
+			project: document.getElementById("log-project").value,
+			task: document.getElementById("log-task").value,
+			start: start.toISOString(),
+			end: end.toISOString(),
+			pause_seconds: (Number(document.getElementById("log-pause").value) || 0) * 60,
+		});
+		await loadAll();
+	});
+};
+
+document.getElementById("log-date").valueAsDate = new Date();
+
+run(loadAll);
+setInterval(tick, 1000);
+// Timer may be started or stopped from the terminal
+setInterval(() => run(loadTimer), 15000);
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// One exported line, column name -> value
+type ExportRow map[string]interface{}
+
+// Columns that can be exported, first list is the default
+var exportColumns = map[string][][]string{
+	"session": {
+		{"date", "start", "end", "activity", "project", "task", "pause", "minutes", "hours"},
+		{"id", "seconds"},
+	},
+	"activity": {
+		{"activity", "minutes", "hours", "percent"},
+		{"seconds"},
+	},
+	"project": {
+		{"activity", "project", "minutes", "hours", "percent"},
+		{"seconds"},
+	},
+}
+
+// tm export [today|week|month] [--from] [--to] [--by] [--format] [--columns] [--output]
+func CmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "first day YYYY-MM-DD")
+	toFlag := fs.String("to", "", "last day YYYY-MM-DD")
+	by := fs.String("by", "session", "session, activity or project")
+	format := fs.String("format", "csv", "csv, jsonl or md")
+	columnsFlag := fs.String("columns", "", "comma separated columns")
+	output := fs.String("output", "", "file to write, stdout if empty")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: tm " + subcommands["export"].Usage)
+	}
+
+	period := "week"
+	if len(positional) == 1 {
+		period = positional[0]
+	}
+
+	from, to, err := ReportRange(period, *fromFlag, *toFlag, app.Now())
+	if err != nil {
+		return err
+	}
+
+	columns, err := ExportColumns(*by, *columnsFlag)
+	if err != nil {
+		return err
+	}
+
+	rows := ExportRows(OpenAndGetDataFromJson(), *by, from, to)
+
+	// Write to buffer first, so a bad format does not leave a half written file
+	var buffer bytes.Buffer
+	err = WriteExport(&buffer, *format, columns, rows)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = app.Out.Write(buffer.Bytes())
+		return err
+	}
+
+	err = WriteFileAtomic(*output, buffer.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	Feedback("<< Exported ", len(rows), " rows to ", false)
+	Feedback("", *output, " >>\n", false)
+	return nil
+}
+
+// Check selected columns, empty selection gives the default columns
+func ExportColumns(by string, selected string) ([]string, error) {
+	available, ok := exportColumns[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown --by '%s', use session, activity or project", by)
+	}
+	if selected == "" {
+		return available[0], nil
+	}
+
+	columns := []string{}
+	for _, column := range strings.Split(selected, ",") {
+		column = strings.TrimSpace(column)
+
+		found := false
+		for _, group := range available {
+			for _, name := range group {
+				found = found || name == column
+			}
+		}
+		if !found {
+			all := []string{}
+			for _, group := range available {
+				all = append(all, group...)
+			}
+			return nil, fmt.Errorf("unknown column '%s', use %s", column, strings.Join(all, ","))
+		}
+
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// Sessions or totals by activity or project in [from, to)
+func ExportRows(data []JsonData, by string, from time.Time, to time.Time) []ExportRow {
+	rows := []ExportRow{}
+
+	if by == "session" {
+		// Activity names by id
+		names := map[int]string{}
+		for _, activity := range data {
+			names[activity.Id] = activity.Activity
+		}
+
+		for _, session := range SessionsInRange(data, from, to) {
+			// Minutes are rounded by the rounding policy, seconds are exact
+			minutes := SessionMinutes(session)
+			rows = append(rows, ExportRow{
+				"id":       session.Id,
+				"date":     session.Start.Format("2006-01-02"),
+				"start":    session.Start.Format("15:04"),
+				"end":      session.End.Format("15:04"),
+				"activity": names[session.ActivityId],
+				"project":  session.Project,
+				"task":     session.Task,
+				"pause":    int(math.Round(float64(session.PauseSeconds) / 60)),
+				"seconds":  Seconds(SessionDuration(session)),
+				"minutes":  minutes,
+				"hours":    Hours(minutes),
+			})
+		}
+		return rows
+	}
+
+	report := BuildReport(data, from, to)
+	for _, activity := range report.Activities {
+		if by == "activity" {
+			rows = append(rows, AggregateRow(activity, report.Seconds, ExportRow{"activity": activity.Name}))
+			continue
+		}
+		for _, project := range activity.Items {
+			rows = append(rows, AggregateRow(project, report.Seconds, ExportRow{"activity": activity.Name, "project": project.Name}))
+		}
+	}
+	return rows
+}
+
+// Add time columns of a report item to row, total is in seconds
+func AggregateRow(item ReportItem, total int, row ExportRow) ExportRow {
+	row["minutes"] = item.Minutes
+	row["seconds"] = item.Seconds
+	row["hours"] = Hours(item.Minutes)
+	row["percent"] = Percent(item.Seconds, total)
+	return row
+}
+
+// Minutes as decimal hours for spreadsheets: 90 -> 1.5
+func Hours(minutes int) float64 {
+	return math.Round(float64(minutes)/60*100) / 100
+}
+
+// Write rows as csv, jsonl or markdown table
+func WriteExport(w io.Writer, format string, columns []string, rows []ExportRow) error {
+	switch format {
+	case "csv":
+		return WriteCSV(w, columns, rows)
+	case "jsonl":
+		return WriteJSONL(w, columns, rows)
+	case "md", "markdown":
+		return WriteMarkdown(w, columns, rows)
+	}
+	return fmt.Errorf("unknown --format '%s', use csv, jsonl or md", format)
+}
+
+func WriteCSV(w io.Writer, columns []string, rows []ExportRow) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(columns)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		err = writer.Write(RowValues(row, columns))
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// One json object per line with selected columns only
+func WriteJSONL(w io.Writer, columns []string, rows []ExportRow) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		selected := ExportRow{}
+		for _, column := range columns {
+			selected[column] = row[column]
+		}
+
+		err := encoder.Encode(selected)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteMarkdown(w io.Writer, columns []string, rows []ExportRow) error {
+	lines := []string{
+		"| " + strings.Join(columns, " | ") + " |",
+		"|" + strings.Repeat(" --- |", len(columns)),
+	}
+
+	for _, row := range rows {
+		values := RowValues(row, columns)
+		for i, value := range values {
+			values[i] = strings.ReplaceAll(value, "|", "\\|")
+		}
+		lines = append(lines, "| "+strings.Join(values, " | ")+" |")
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// Values of selected columns as text
+func RowValues(row ExportRow, columns []string) []string {
+	values := []string{}
+	for _, column := range columns {
+		value, ok := row[column]
+		if !ok {
+			value = ""
+		}
+		values = append(values, fmt.Sprint(value))
+	}
+	return values
+}
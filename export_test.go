@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Same rows in every format, selected columns only and in their order
+func TestWriteExport(t *testing.T) {
+	rows := []ExportRow{
+		{"date": "2026-03-02", "activity": "coding", "project": "api|web", "minutes": 90, "hours": 1.5},
+		{"date": "2026-03-02", "activity": "writing, notes", "project": "", "minutes": 30, "hours": 0.5},
+	}
+	columns := []string{"activity", "project", "hours"}
+
+	tests := []struct {
+		format string
+		want   string
+		err    bool
+	}{
+		{"csv", "activity,project,hours\ncoding,api|web,1.5\n\"writing, notes\",,0.5\n", false},
+		{"jsonl", `{"activity":"coding","hours":1.5,"project":"api|web"}` + "\n" + `{"activity":"writing, notes","hours":0.5,"project":""}` + "\n", false},
+		{"md", "| activity | project | hours |\n| --- | --- | --- |\n| coding | api\\|web | 1.5 |\n| writing, notes |  | 0.5 |\n", false},
+		{"xml", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var out bytes.Buffer
+			err := WriteExport(&out, test.format, columns, rows)
+			if (err != nil) != test.err {
+				t.Fatalf("error %v", err)
+			}
+			if out.String() != test.want {
+				t.Errorf("wrote\n%s\nwant\n%s", out.String(), test.want)
+			}
+		})
+	}
+}
+
+// Default columns, chosen ones and unknown ones
+func TestExportColumns(t *testing.T) {
+	tests := []struct {
+		by       string
+		selected string
+		want     string
+		err      bool
+	}{
+		{"session", "", "date,start,end,activity,project,task,pause,minutes,hours", false},
+		{"session", "id, seconds", "id,seconds", false},
+		{"project", "", "activity,project,minutes,hours,percent", false},
+		{"activity", "project", "", true},
+		{"day", "", "", true},
+	}
+
+	for _, test := range tests {
+		columns, err := ExportColumns(test.by, test.selected)
+		if (err != nil) != test.err || strings.Join(columns, ",") != test.want {
+			t.Errorf("%s %q: %v (%v), want %s", test.by, test.selected, columns, err, test.want)
+		}
+	}
+}
+
+// Export without --output goes to the output of the app
+func TestExportToOutput(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+	RunScript(t, clock, "c", "+1h", "q", "", "q")
+
+	var out bytes.Buffer
+	app.Out = NewTerminal(&out)
+	if code := RunSubcommand([]string{"export", "--by", "activity", "--columns", "activity,minutes"}); code != 0 {
+		t.Fatalf("export exit code %d", code)
+	}
+	if out.String() != "activity,minutes\ncoding,60\n" {
+		t.Errorf("wrote %q", out.String())
+	}
+}
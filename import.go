@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Time entry read from another tracker
+type ImportEntry struct {
+	Project string
+	Tags    []string
+	Start   time.Time
+	End     time.Time
+}
+
+// What an import will change
+type ImportPlan struct {
+	// New activities with their short names, new projects and sessions by activity name
+	NewActivities []JsonData
+	NewProjects   map[string][]string
+	Sessions      map[string][]Session
+
+	// Ids of the activities that are there already
+	ActivityIds map[string]int
+
+	// Names that were taken or not allowed: 'api' -> 'api (imported)'
+	Renamed []string
+
+	// Sessions left out, saved already or overlapping saved or imported time
+	Duplicates int
+	Overlaps   int
+}
+
+// tm import <file> [--format csv|timewarrior|timeclock] [--activity A] [--apply]
+func CmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "csv, timewarrior or timeclock, guessed from file if empty")
+	activity := fs.String("activity", "", "import everything into this activity")
+	apply := fs.Bool("apply", false, "save the import, without it nothing is changed")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tm " + subcommands["import"].Usage)
+	}
+
+	entries, err := ReadImportFile(positional[0], *format)
+	if err != nil {
+		return err
+	}
+
+	plan := PlanImport(OpenAndGetDataFromJson(), entries, *activity)
+	PrintImportPlan(plan)
+
+	if !*apply {
+		Feedback("\n<< Dry run, nothing saved. Run again with ", "--apply", " to import >>\n", false)
+		return nil
+	}
+
+	err = ApplyImport(plan)
+	if err != nil {
+		return err
+	}
+	Feedback("\n<< ", "IMPORT HAS BEEN SAVED", " >>\n", false)
+	return nil
+}
+
+// Open file and parse it in the given or guessed format
+func ReadImportFile(path string, format string) ([]ImportEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if format == "" {
+		format, err = GuessImportFormat(path, reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case "csv", "toggl", "clockify":
+		return ParseTrackerCSV(reader)
+	case "timewarrior":
+		return ParseTimewarrior(reader)
+	case "timeclock", "ledger":
+		return ParseTimeclock(reader)
+	}
+	return nil, fmt.Errorf("unknown --format '%s', use csv, timewarrior or timeclock", format)
+}
+
+// Guess format from file name or first line
+func GuessImportFormat(path string, reader *bufio.Reader) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv", nil
+	case ".data":
+		return "timewarrior", nil
+	case ".timeclock":
+		return "timeclock", nil
+	}
+
+	first, _ := reader.Peek(64)
+	line := strings.TrimSpace(string(first))
+	switch {
+	case strings.HasPrefix(line, "inc "):
+		return "timewarrior", nil
+	case strings.HasPrefix(line, "i "):
+		return "timeclock", nil
+	case strings.Contains(line, ","):
+		return "csv", nil
+	}
+	return "", errors.New("can't guess format, use --format")
+}
+
+// Toggl and Clockify csv exports. Columns are found by header name.
+func ParseTrackerCSV(r io.Reader) ([]ImportEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// Column index by lowercase name
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"project", "start date", "start time", "end date", "end time"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv has no '%s' column", name)
+		}
+	}
+
+	get := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	entries := []ImportEntry{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		start, err := ParseImportTime(get(record, "start date"), get(record, "start time"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := ParseImportTime(get(record, "end date"), get(record, "end time"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		// Clockify task comes before tags
+		tags := []string{}
+		if task := get(record, "task"); task != "" {
+			tags = append(tags, task)
+		}
+		for _, tag := range strings.Split(get(record, "tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+
+		entries = append(entries, ImportEntry{Project: get(record, "project"), Tags: tags, Start: start, End: end})
+	}
+	return entries, nil
+}
+
+// Date and time in the layouts Toggl and Clockify use
+func ParseImportTime(date string, clock string) (time.Time, error) {
+	dateLayouts := []string{"2006-01-02", "01/02/2006", "02.01.2006", "2006/01/02"}
+	clockLayouts := []string{"15:04:05", "15:04", "03:04:05 PM", "03:04 PM", "3:04:05 PM", "3:04 PM"}
+
+	for _, dateLayout := range dateLayouts {
+		for _, clockLayout := range clockLayouts {
+			t, err := time.ParseInLocation(dateLayout+" "+clockLayout, date+" "+clock, time.Local)
+			if err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't read time '%s %s'", date, clock)
+}
+
+// Timewarrior data files: inc 20240115T090000Z - 20240115T100000Z # tag "other tag"
+// First tag is used as project. Open intervals are skipped.
+func ParseTimewarrior(r io.Reader) ([]ImportEntry, error) {
+	entries := []ImportEntry{}
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(text, "inc ") {
+			continue
+		}
+
+		interval, tagText, _ := strings.Cut(strings.TrimPrefix(text, "inc "), "#")
+		times := strings.Fields(interval)
+		if len(times) != 3 || times[1] != "-" {
+			continue
+		}
+
+		start, err := time.Parse("20060102T150405Z", times[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := time.Parse("20060102T150405Z", times[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		entry := ImportEntry{Start: start.Local(), End: end.Local()}
+		tags := SplitTimewarriorTags(tagText)
+		if len(tags) > 0 {
+			entry.Project, entry.Tags = tags[0], tags[1:]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Split tags on spaces, "quoted tags" may contain spaces
+func SplitTimewarriorTags(text string) []string {
+	tags := []string{}
+	quoted := false
+	current := ""
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if current != "" {
+				tags = append(tags, current)
+			}
+			current = ""
+		default:
+			current += string(r)
+		}
+	}
+	if current != "" {
+		tags = append(tags, current)
+	}
+	return tags
+}
+
+// Ledger timeclock: i 2024/01/15 09:00:00 Account:Sub  payee / o 2024/01/15 10:00:00
+// First part of the account is used as project, the rest as tag.
+func ParseTimeclock(r io.Reader) ([]ImportEntry, error) {
+	entries := []ImportEntry{}
+	scanner := bufio.NewScanner(r)
+
+	var open *ImportEntry
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || (fields[0] != "i" && fields[0] != "o" && fields[0] != "O") {
+			continue
+		}
+
+		at, err := time.ParseInLocation("2006/01/02 15:04:05", fields[1]+" "+fields[2], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if fields[0] == "i" {
+			entry := ImportEntry{Start: at}
+			if len(fields) > 3 {
+				account := strings.SplitN(fields[3], ":", 2)
+				entry.Project = account[0]
+				if len(account) == 2 {
+					entry.Tags = []string{account[1]}
+				}
+			}
+			open = &entry
+			continue
+		}
+
+		if open == nil {
+			return nil, fmt.Errorf("line %d: clock out without clock in", line)
+		}
+		open.End = at
+		entries = append(entries, *open)
+		open = nil
+	}
+	return entries, scanner.Err()
+}
+
+// Map entries onto activities and projects. Their project is our activity and their first tag
+// our project, or with activity given everything goes there and their project is our project.
+// Activities are found by name or short name, archived ones are left alone. New activities
+// and projects get names that pass the same checks as when they are added by hand.
+func PlanImport(data []JsonData, entries []ImportEntry, activity string) ImportPlan {
+	plan := ImportPlan{NewProjects: map[string][]string{}, Sessions: map[string][]Session{}, ActivityIds: map[string]int{}}
+
+	// Saved data with the planned names added, new names are checked against both
+	known := append([]JsonData{}, data...)
+
+	// Names in the file and what they become here, projects by activity and project
+	activityNames, projectNames := map[string]string{}, map[[2]string]string{}
+
+	// Sessions planned so far, imported time can't overlap itself either
+	imported := []JsonData{{Activity: "import", Sessions: []Session{}}}
+
+	for _, entry := range entries {
+		if !entry.End.After(entry.Start) {
+			continue
+		}
+
+		source, project := entry.Project, ""
+		if len(entry.Tags) > 0 {
+			project = entry.Tags[0]
+		}
+		if activity != "" {
+			source, project = activity, entry.Project
+		}
+		if source == "" {
+			source = "imported"
+		}
+
+		// Find or plan activity
+		name, ok := activityNames[source]
+		if !ok {
+			if existing, found := FindImportTarget(data, source); found {
+				name = existing.Activity
+				plan.ActivityIds[name] = existing.Id
+			} else {
+				name = ImportName(source, func(name string) error { return CheckActivityName(name, known) })
+				planned := JsonData{Activity: name, Short: UniqueShortName(known, name), Projects: []Project{}, Sessions: []Session{}}
+				plan.NewActivities = append(plan.NewActivities, planned)
+				known = append(known, planned)
+				plan.rename(source, name)
+			}
+			activityNames[source] = name
+		}
+		existing, found := FindImportTarget(data, name)
+
+		// Find or plan project
+		if project != "" {
+			key := [2]string{name, project}
+			renamed, ok := projectNames[key]
+			if !ok {
+				renamed = project
+				if !HasProject(existing, project) {
+					renamed = ImportName(project, func(name string) error { return CheckProjectName(name, known) })
+					plan.NewProjects[name] = append(plan.NewProjects[name], renamed)
+					known = append(known, JsonData{Projects: []Project{{Name: renamed}}})
+					plan.rename(project, renamed)
+				}
+				projectNames[key] = renamed
+			}
+			project = renamed
+		}
+
+		session := Session{ActivityId: existing.Id, Project: project, Start: entry.Start, End: entry.End}
+		if found && HasSession(existing, session) {
+			plan.Duplicates++
+			continue
+		}
+
+		// Time is never counted twice
+		if CheckOverlap(data, session.Start, session.End, -1) != nil || CheckOverlap(imported, session.Start, session.End, -1) != nil {
+			plan.Overlaps++
+			continue
+		}
+		imported[0].Sessions = append(imported[0].Sessions, session)
+		plan.Sessions[name] = append(plan.Sessions[name], session)
+	}
+	return plan
+}
+
+// Remember that a name from the file is saved under another one
+func (p *ImportPlan) rename(from string, to string) {
+	if from != to {
+		p.Renamed = append(p.Renamed, fmt.Sprintf("'%s' -> '%s'", from, to))
+	}
+}
+
+// Name that passes check: name, name (imported), name (imported 2), ...
+func ImportName(name string, check func(name string) error) string {
+	candidate := name
+	for n := 1; check(candidate) != nil; n++ {
+		candidate = name + " (imported)"
+		if n > 1 {
+			candidate = fmt.Sprintf("%s (imported %d)", name, n)
+		}
+	}
+	return candidate
+}
+
+func PrintImportPlan(plan ImportPlan) {
+	for _, activity := range plan.NewActivities {
+		Feedback("<< New activity: ", activity.Activity, " ("+activity.Short+") >>\n", false)
+	}
+	for _, renamed := range plan.Renamed {
+		Feedback("<< Renamed, name is taken or a command: ", renamed, " >>\n", false)
+	}
+
+	names := []string{}
+	for name := range plan.Sessions {
+		names = append(names, name)
+	}
+	for name := range plan.NewProjects {
+		if _, ok := plan.Sessions[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	total := time.Duration(0)
+	for _, name := range names {
+		for _, project := range plan.NewProjects[name] {
+			Feedback("<< New project: ", name+" / "+project, " >>\n", false)
+		}
+	}
+	for _, name := range names {
+		duration := time.Duration(0)
+		for _, session := range plan.Sessions[name] {
+			duration += app.Rounding.Counted(SessionDuration(session))
+		}
+		total += duration
+
+		Feedback("<< [", name, "] ", false)
+		Feedback("", len(plan.Sessions[name]), " sessions ", false)
+		Feedback("(", FormatMinutes(app.Rounding.Round(duration)), ") >>\n", false)
+	}
+
+	Feedback("<< Total: ", FormatMinutes(app.Rounding.Round(total)), "", false)
+	Feedback(", already saved: ", plan.Duplicates, " sessions", false)
+	Feedback(", overlapping saved time: ", plan.Overlaps, " sessions (left out) >>\n", false)
+}
+
+// Create missing activities and projects and add all sessions at once, all or nothing
+func ApplyImport(plan ImportPlan) error {
+	projects := func(names []string) []Project {
+		projects := []Project{}
+		for _, name := range names {
+			projects = append(projects, Project{Name: name, Tasks: []Task{}})
+		}
+		return projects
+	}
+
+	activities := []JsonData{}
+	for _, activity := range plan.NewActivities {
+		activity.Projects = projects(plan.NewProjects[activity.Activity])
+		activity.Sessions = append([]Session{}, plan.Sessions[activity.Activity]...)
+		activities = append(activities, activity)
+	}
+
+	additions := []JsonData{}
+	for name, id := range plan.ActivityIds {
+		if len(plan.NewProjects[name]) == 0 && len(plan.Sessions[name]) == 0 {
+			continue
+		}
+		additions = append(additions, JsonData{Id: id, Activity: name, Projects: projects(plan.NewProjects[name]), Sessions: plan.Sessions[name]})
+	}
+	sort.Slice(additions, func(i, j int) bool { return additions[i].Id < additions[j].Id })
+
+	_, err := app.Store.Import(activities, additions)
+	return err
+}
+
+// Short name from first letters that is not used yet: "Client work" -> cw, cw2, ...
+func UniqueShortName(data []JsonData, name string) string {
+	base := ""
+	for _, word := range strings.Fields(strings.ToLower(name)) {
+		first := []rune(word)[0]
+		if unicode.IsLetter(first) || unicode.IsDigit(first) {
+			base += string(first)
+		}
+	}
+	if base == "" {
+		base = "i"
+	}
+
+	short := base
+	for n := 2; ; n++ {
+		_, used := FindActivity(data, short)
+		if !used && !IsReservedWord(short) {
+			return short
+		}
+		short = fmt.Sprint(base, n)
+	}
+}
+
+// Activity imported time goes to, found by name or short name. Archived ones take nothing.
+func FindImportTarget(data []JsonData, name string) (JsonData, bool) {
+	for _, activity := range data {
+		if !activity.Archived && (activity.Activity == name || activity.Short == name) {
+			return activity, true
+		}
+	}
+	return JsonData{}, false
+}
+
+func HasProject(activity JsonData, name string) bool {
+	for _, project := range activity.Projects {
+		if project.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Same activity, start and end is already saved
+func HasSession(activity JsonData, session Session) bool {
+	for _, saved := range activity.Sessions {
+		if saved.Start.Equal(session.Start) && saved.End.Equal(session.End) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Activities are found by short name too, taken names are renamed and overlapping time is left out
+func TestImportChecksNamesAndOverlaps(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+	RunScript(t, clock, "c", "a", "api", "s", "0", "+1h", "q", "", "q")
+
+	at := func(hours int) time.Time { return testStart.Add(time.Duration(hours) * time.Hour) }
+	entries := []ImportEntry{
+		{Project: "c", Tags: []string{"api"}, Start: at(-3), End: at(-2)},
+		{Project: "writing", Tags: []string{"api"}, Start: at(-5), End: at(-4)},
+		{Project: "top", Start: at(-7), End: at(-6)},
+		{Project: "writing", Start: at(-5).Add(30 * time.Minute), End: at(-3)},
+		{Project: "coding", Start: at(0).Add(30 * time.Minute), End: at(2)},
+	}
+	plan := PlanImport(ReadDataFile(t), entries, "")
+
+	if len(plan.NewActivities) != 2 || plan.NewActivities[1].Activity != "top (imported)" || plan.NewProjects["writing"][0] != "api (imported)" {
+		t.Fatalf("plan %+v", plan)
+	}
+	if plan.Overlaps != 2 || len(plan.Sessions["coding"]) != 1 {
+		t.Fatalf("plan %+v", plan)
+	}
+
+	err := ApplyImport(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := ReadDataFile(t)
+	if len(data) != 3 || data[1].Activity != "writing" || data[1].Projects[0].Name != "api (imported)" || len(data[2].Sessions) != 1 {
+		t.Fatalf("data %+v", data)
+	}
+	AssertSession(t, data[1].Sessions[0], at(-5), at(-4), 0, "api (imported)")
+	AssertSession(t, data[0].Sessions[len(data[0].Sessions)-1], at(-3), at(-2), 0, "api")
+}
+
+// Ids and archived activities take no imported time, a new activity does
+func TestImportSkipsIdsAndArchived(t *testing.T) {
+	NewTestApp(t, "coding c", "writing w")
+	err := app.Store.ArchiveActivity(1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []ImportEntry{
+		{Project: "0", Start: testStart.Add(-3 * time.Hour), End: testStart.Add(-2 * time.Hour)},
+		{Project: "w", Start: testStart.Add(-5 * time.Hour), End: testStart.Add(-4 * time.Hour)},
+	}
+	plan := PlanImport(ReadDataFile(t), entries, "")
+
+	if len(plan.NewActivities) != 2 || plan.NewActivities[0].Activity != "0" || plan.NewActivities[1].Activity != "w (imported)" {
+		t.Fatalf("plan %+v", plan)
+	}
+	if len(plan.ActivityIds) != 0 {
+		t.Fatalf("plan %+v", plan)
+	}
+}
+
+// Nothing is saved when a part of the import fails
+func TestImportIsAllOrNothing(t *testing.T) {
+	NewTestApp(t, "coding c")
+
+	_, err := app.Store.Import([]JsonData{{Activity: "writing", Short: "w"}}, []JsonData{{Id: 99, Sessions: []Session{{Start: testStart, End: testStart.Add(time.Hour)}}}})
+	if err == nil {
+		t.Fatal("import into a missing activity saved")
+	}
+	if data := ReadDataFile(t); len(data) != 1 {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+// Parsers with the entries they should read, or an error
+func TestParseImportFiles(t *testing.T) {
+	at := func(hour int, minute int) time.Time { return time.Date(2024, 1, 15, hour, minute, 0, 0, time.Local) }
+	tests := []struct {
+		name    string
+		parse   func(r io.Reader) ([]ImportEntry, error)
+		file    string
+		entries []ImportEntry
+		err     string
+	}{
+		{"toggl csv", ParseTrackerCSV, "\ufeffUser,Project,Description,Start date,Start time,End date,End time,Tags\n" +
+			"me,Client,fix,2024-01-15,09:00:00,2024-01-15,10:30:00,\"api, docs\"\n",
+			[]ImportEntry{{Project: "Client", Tags: []string{"api", "docs"}, Start: at(9, 0), End: at(10, 30)}}, ""},
+		{"clockify csv", ParseTrackerCSV, "Project,Task,Start Date,Start Time,End Date,End Time,Tags\n" +
+			"Client,api,01/15/2024,09:00 AM,01/15/2024,01:15 PM,docs\n",
+			[]ImportEntry{{Project: "Client", Tags: []string{"api", "docs"}, Start: at(9, 0), End: at(13, 15)}}, ""},
+		{"csv without end", ParseTrackerCSV, "Project,Start date,Start time\n", nil, "no 'end date' column"},
+		{"csv bad time", ParseTrackerCSV, "Project,Start date,Start time,End date,End time\nClient,15/01/2024,9,15/01/2024,10\n", nil, "line 2"},
+		{"timewarrior", ParseTimewarrior, "inc 20240115T090000Z - 20240115T100000Z # Client \"code review\" api\n" +
+			"inc 20240115T110000Z # open\nnot an interval\n",
+			[]ImportEntry{{Project: "Client", Tags: []string{"code review", "api"},
+				Start: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC).Local(), End: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC).Local()}}, ""},
+		{"timewarrior bad time", ParseTimewarrior, "inc 2024-01-15 - 20240115T100000Z\n", nil, "line 1"},
+		{"timeclock", ParseTimeclock, "; comment\ni 2024/01/15 09:00:00 Client:api  fix\no 2024/01/15 10:00:00\n" +
+			"i 2024/01/15 11:00:00\nO 2024/01/15 11:30:00\n",
+			[]ImportEntry{{Project: "Client", Tags: []string{"api"}, Start: at(9, 0), End: at(10, 0)}, {Start: at(11, 0), End: at(11, 30)}}, ""},
+		{"timeclock out without in", ParseTimeclock, "o 2024/01/15 10:00:00\n", nil, "clock out without clock in"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := test.parse(strings.NewReader(test.file))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != len(test.entries) {
+				t.Fatalf("entries %+v, want %+v", entries, test.entries)
+			}
+			for i, entry := range entries {
+				want := test.entries[i]
+				if entry.Project != want.Project || strings.Join(entry.Tags, ",") != strings.Join(want.Tags, ",") ||
+					!entry.Start.Equal(want.Start) || !entry.End.Equal(want.End) {
+					t.Errorf("entry %+v, want %+v", entry, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,858 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+var journalFilename = "data/journal.json"
+
+// Operations kept for undo, older ones are forgotten
+var journalSize = 100
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// Operations that can be undone and undone ones that can be redone, newest last
+type Journal struct {
+	Undo []JournalEntry `json:"undo"`
+	Redo []JournalEntry `json:"redo"`
+}
+
+// One operation with what it changed
+type JournalEntry struct {
+	Time        time.Time       `json:"time"`
+	Description string          `json:"description"`
+	Changes     []JournalChange `json:"changes"`
+}
+
+// Activity, project, task or session as it was before and after an operation.
+// Before is left out for something added, After for something deleted. Activities
+// are kept without their projects and sessions, projects without their tasks.
+type JournalChange struct {
+	Kind string `json:"kind"`
+	Id   int    `json:"id"`
+
+	// Activity of a project or session, project of a task
+	ParentId int `json:"parent_id"`
+
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Kinds of changes, parents come first
+var journalKinds = []string{"activity", "project", "task", "session"}
+
+// Load journal, empty if nothing has been journaled yet
+func LoadJournal() (Journal, error) {
+	journal := Journal{Undo: []JournalEntry{}, Redo: []JournalEntry{}}
+
+	file, err := ioutil.ReadFile(journalFilename)
+	if os.IsNotExist(err) {
+		return journal, nil
+	} else if err != nil {
+		return journal, err
+	}
+
+	err = json.Unmarshal(file, &journal)
+	if err != nil {
+		return journal, fmt.Errorf("%s: %w", journalFilename, err)
+	}
+	return journal, nil
+}
+
+// Run change with the data locked for the journal. Every change of the store takes
+// the lock, so nothing else is changed between reading the data and journaling it.
+func WithDataLock(run func() error) (err error) {
+	lock, err := LockFile(journalFilename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := lock.Unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	return run()
+}
+
+// Load, change and save journal with the data locked
+func UpdateJournal(change func(journal *Journal) error) error {
+	return WithDataLock(func() error {
+		return changeJournal(change)
+	})
+}
+
+// Load, change and save journal, the data lock must be held
+func changeJournal(change func(journal *Journal) error) error {
+	journal, err := LoadJournal()
+	if err != nil {
+		return err
+	}
+
+	err = change(&journal)
+	if err != nil {
+		return err
+	}
+
+	dataBytes, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(journalFilename, dataBytes, 0644)
+}
+
+// Last count entries, newest first
+func LastEntries(entries []JournalEntry, count int) []JournalEntry {
+	last := []JournalEntry{}
+	for i := len(entries) - 1; i >= 0 && len(last) < count; i-- {
+		last = append(last, entries[i])
+	}
+	return last
+}
+
+/*<=================================================== Journal store ===================================================>*/
+
+// Store that writes every change to the journal so it can be undone
+type JournalStore struct {
+	Store
+}
+
+func NewJournalStore(store Store) *JournalStore {
+	return &JournalStore{Store: store}
+}
+
+func (s *JournalStore) AddActivity(activity JsonData) (JsonData, error) {
+	added := activity
+	err := s.record(func(data []JsonData) (string, []int) {
+		return fmt.Sprintf("add activity '%s'", activity.Activity), nil
+	}, func() ([]int, error) {
+		var err error
+		added, err = s.Store.AddActivity(activity)
+		return []int{added.Id}, err
+	})
+	return added, err
+}
+
+func (s *JournalStore) ArchiveActivity(id int, archived bool) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		return fmt.Sprintf("%s '%s'", ArchiveVerb(archived), ActivityName(id, data)), []int{id}
+	}, func() ([]int, error) {
+		return nil, s.Store.ArchiveActivity(id, archived)
+	})
+}
+
+func (s *JournalStore) RenameActivity(id int, name string, short string) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		return fmt.Sprintf("rename '%s' to '%s' (%s)", ActivityName(id, data), name, short), []int{id}
+	}, func() ([]int, error) {
+		return nil, s.Store.RenameActivity(id, name, short)
+	})
+}
+
+func (s *JournalStore) DeleteActivity(id int) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		return fmt.Sprintf("delete '%s'", ActivityName(id, data)), []int{id}
+	}, func() ([]int, error) {
+		return nil, s.Store.DeleteActivity(id)
+	})
+}
+
+func (s *JournalStore) AddProject(activityId int, project Project) (Project, error) {
+	added := project
+	err := s.record(func(data []JsonData) (string, []int) {
+		return fmt.Sprintf("add project '%s' to '%s'", project.Name, ActivityName(activityId, data)), []int{activityId}
+	}, func() ([]int, error) {
+		var err error
+		added, err = s.Store.AddProject(activityId, project)
+		return nil, err
+	})
+	return added, err
+}
+
+func (s *JournalStore) ArchiveProject(id int, archived bool) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		activityIndex, projectIndex, ok := FindProject(id, data)
+		if !ok {
+			return "", nil
+		}
+		activity := data[activityIndex]
+		return fmt.Sprintf("%s project '%s' of '%s'", ArchiveVerb(archived), activity.Projects[projectIndex].Name, activity.Activity), []int{activity.Id}
+	}, func() ([]int, error) {
+		return nil, s.Store.ArchiveProject(id, archived)
+	})
+}
+
+func (s *JournalStore) DeleteProject(id int) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		activityIndex, projectIndex, ok := FindProject(id, data)
+		if !ok {
+			return "", nil
+		}
+		activity := data[activityIndex]
+		return fmt.Sprintf("delete project '%s' of '%s'", activity.Projects[projectIndex].Name, activity.Activity), []int{activity.Id}
+	}, func() ([]int, error) {
+		return nil, s.Store.DeleteProject(id)
+	})
+}
+
+func (s *JournalStore) RenameProject(id int, name string) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		activityIndex, projectIndex, ok := FindProject(id, data)
+		if !ok {
+			return "", nil
+		}
+		activity := data[activityIndex]
+		return fmt.Sprintf("rename project '%s' of '%s' to '%s'", activity.Projects[projectIndex].Name, activity.Activity, name), []int{activity.Id}
+	}, func() ([]int, error) {
+		return nil, s.Store.RenameProject(id, name)
+	})
+}
+
+func (s *JournalStore) AddTask(projectId int, task Task) (Task, error) {
+	added := task
+	err := s.record(func(data []JsonData) (string, []int) {
+		activityIndex, projectIndex, ok := FindProject(projectId, data)
+		if !ok {
+			return "", nil
+		}
+		activity := data[activityIndex]
+		return fmt.Sprintf("add task '%s' to '%s'", task.Name, activity.Projects[projectIndex].Name), []int{activity.Id}
+	}, func() ([]int, error) {
+		var err error
+		added, err = s.Store.AddTask(projectId, task)
+		return nil, err
+	})
+	return added, err
+}
+
+func (s *JournalStore) DeleteTask(id int) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		activityIndex, projectIndex, taskIndex, ok := FindTask(id, data)
+		if !ok {
+			return "", nil
+		}
+		project := data[activityIndex].Projects[projectIndex]
+		return fmt.Sprintf("delete task '%s' of '%s'", project.Tasks[taskIndex].Name, project.Name), []int{data[activityIndex].Id}
+	}, func() ([]int, error) {
+		return nil, s.Store.DeleteTask(id)
+	})
+}
+
+func (s *JournalStore) RenameTask(id int, name string) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		activityIndex, projectIndex, taskIndex, ok := FindTask(id, data)
+		if !ok {
+			return "", nil
+		}
+		project := data[activityIndex].Projects[projectIndex]
+		return fmt.Sprintf("rename task '%s' of '%s' to '%s'", project.Tasks[taskIndex].Name, project.Name, name), []int{data[activityIndex].Id}
+	}, func() ([]int, error) {
+		return nil, s.Store.RenameTask(id, name)
+	})
+}
+
+func (s *JournalStore) UpdateTask(task Task) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		activityIndex, projectIndex, taskIndex, ok := FindTask(task.Id, data)
+		if !ok {
+			return "", nil
+		}
+		project := data[activityIndex].Projects[projectIndex]
+		return DescribeTaskChange(project.Tasks[taskIndex], task, project.Name), []int{data[activityIndex].Id}
+	}, func() ([]int, error) {
+		return nil, s.Store.UpdateTask(task)
+	})
+}
+
+// Describe the one thing that changed in task, most changes change only one
+func DescribeTaskChange(old Task, task Task, project string) string {
+	name := fmt.Sprintf("task '%s' of '%s'", old.Name, project)
+	switch {
+	case old.Status != task.Status && task.Status == TaskDone:
+		return "mark " + name + " done"
+	case old.Status != task.Status && old.Status == TaskDone:
+		return "reopen " + name
+	case old.Status != task.Status:
+		return "mark " + name + " " + task.Status
+	case old.Priority != task.Priority:
+		return "set priority of " + name + " to " + PriorityName(task.Priority)
+	case task.Due != nil && (old.Due == nil || !old.Due.Equal(*task.Due)):
+		return "set due date of " + name + " to " + task.Due.Format("02.01.2006")
+	case task.Due == nil && old.Due != nil:
+		return "clear due date of " + name
+	case old.Notes != task.Notes:
+		return "change notes of " + name
+	}
+	return "change " + name
+}
+
+func (s *JournalStore) AddSession(session Session) (Session, error) {
+	added := session
+	err := s.record(func(data []JsonData) (string, []int) {
+		return fmt.Sprintf("save %s of '%s'", FormatMinutes(SessionMinutes(session)), ActivityName(session.ActivityId, data)), []int{session.ActivityId}
+	}, func() ([]int, error) {
+		var err error
+		added, err = s.Store.AddSession(session)
+		return nil, err
+	})
+	return added, err
+}
+
+func (s *JournalStore) AddSessions(sessions []Session) ([]Session, error) {
+	added := []Session{}
+	err := s.record(func(data []JsonData) (string, []int) {
+		ids := []int{}
+		for _, session := range sessions {
+			ids = append(ids, session.ActivityId)
+		}
+		return fmt.Sprintf("save %d sessions", len(sessions)), ids
+	}, func() ([]int, error) {
+		var err error
+		added, err = s.Store.AddSessions(sessions)
+		return nil, err
+	})
+	return added, err
+}
+
+func (s *JournalStore) Import(activities []JsonData, additions []JsonData) ([]JsonData, error) {
+	added := []JsonData{}
+	err := s.record(func(data []JsonData) (string, []int) {
+		ids, count := []int{}, 0
+		for _, addition := range additions {
+			ids = append(ids, addition.Id)
+			count += len(addition.Sessions)
+		}
+		for _, activity := range activities {
+			count += len(activity.Sessions)
+		}
+		return fmt.Sprintf("import %d sessions", count), ids
+	}, func() ([]int, error) {
+		var err error
+		added, err = s.Store.Import(activities, additions)
+		ids := []int{}
+		for _, activity := range added {
+			ids = append(ids, activity.Id)
+		}
+		return ids, err
+	})
+	return added, err
+}
+
+func (s *JournalStore) UpdateSession(session Session) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		ids := []int{session.ActivityId}
+		if activityIndex, _, ok := FindSession(session.Id, data); ok {
+			ids = append(ids, data[activityIndex].Id)
+		}
+		return fmt.Sprintf("change session %d of '%s'", session.Id, ActivityName(session.ActivityId, data)), ids
+	}, func() ([]int, error) {
+		return nil, s.Store.UpdateSession(session)
+	})
+}
+
+func (s *JournalStore) DeleteSession(id int) error {
+	return s.record(func(data []JsonData) (string, []int) {
+		activityIndex, _, ok := FindSession(id, data)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("delete session %d of '%s'", id, data[activityIndex].Activity), []int{data[activityIndex].Id}
+	}, func() ([]int, error) {
+		return nil, s.Store.DeleteSession(id)
+	})
+}
+
+// Run change and journal what it changed in the activities it touched. Describe gets the
+// data before the change and returns what is done and to which activities, change returns
+// ids of new ones. The data stays locked until the entry is written.
+func (s *JournalStore) record(describe func(data []JsonData) (string, []int), change func() ([]int, error)) error {
+	return WithDataLock(func() error {
+		before, err := s.Store.Activities()
+		if err != nil {
+			return err
+		}
+		description, ids := describe(before)
+
+		added, err := change()
+		if err != nil {
+			return err
+		}
+		ids = UniqueIds(append(ids, added...))
+
+		after, err := s.Store.Activities()
+		if err != nil {
+			return err
+		}
+
+		// Nothing to undo
+		changes := DiffActivities(PickActivities(before, ids), PickActivities(after, ids))
+		if len(changes) == 0 {
+			return nil
+		}
+
+		entry := JournalEntry{Time: app.Now(), Description: description, Changes: changes}
+		return changeJournal(func(journal *Journal) error {
+			journal.Undo = append(journal.Undo, entry)
+			if len(journal.Undo) > journalSize {
+				journal.Undo = journal.Undo[len(journal.Undo)-journalSize:]
+			}
+
+			// A new change makes the undone ones impossible to redo
+			journal.Redo = []JournalEntry{}
+			return nil
+		})
+	})
+}
+
+// Undo last count operations, returns the undone ones newest first
+func (s *JournalStore) Undo(count int) ([]JournalEntry, error) {
+	return s.replay(count, true)
+}
+
+// Redo last count undone operations, returns the redone ones
+func (s *JournalStore) Redo(count int) ([]JournalEntry, error) {
+	return s.replay(count, false)
+}
+
+// Move entries from undo to redo putting what they changed back as it was before,
+// or from redo to undo putting it as it was after
+func (s *JournalStore) replay(count int, undo bool) ([]JournalEntry, error) {
+	done := []JournalEntry{}
+	var replayErr error
+
+	err := UpdateJournal(func(journal *Journal) error {
+		from, to := &journal.Undo, &journal.Redo
+		if !undo {
+			from, to = &journal.Redo, &journal.Undo
+		}
+
+		if len(*from) == 0 {
+			replayErr = ErrNothingToRedo
+			if undo {
+				replayErr = ErrNothingToUndo
+			}
+			return nil
+		}
+
+		for len(done) < count && len(*from) > 0 {
+			entry := (*from)[len(*from)-1]
+
+			// Stop at the first failure, the ones done so far stay done
+			replayErr = s.restore(entry.Changes, undo)
+			if replayErr != nil {
+				replayErr = fmt.Errorf("'%s': %w", entry.Description, replayErr)
+				return nil
+			}
+
+			*from = (*from)[:len(*from)-1]
+			*to = append(*to, entry)
+			done = append(done, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return done, err
+	}
+	return done, replayErr
+}
+
+// Put everything the changes touched as it was before them (undo) or after them (redo),
+// after checking that nobody has changed it since. Activities that changed are put whole.
+func (s *JournalStore) restore(changes []JournalChange, undo bool) error {
+	current, err := s.Store.Activities()
+	if err != nil {
+		return err
+	}
+
+	items := map[string]JournalChange{}
+	for _, item := range JournalItems(current) {
+		items[item.Key()] = item
+	}
+	for _, change := range changes {
+		if !SameJson(items[change.Key()].After, change.Now(undo)) {
+			return fmt.Errorf("%s has changed since", change.Name())
+		}
+	}
+
+	data := CopyActivities(current)
+	touched := []int{}
+
+	// Parents are put before their children and removed after them
+	for _, kind := range journalKinds {
+		for _, change := range changes {
+			if change.Kind == kind && change.Wanted(undo) != nil {
+				ids, err := putJournalItem(&data, change, change.Wanted(undo))
+				if err != nil {
+					return err
+				}
+				touched = append(touched, ids...)
+			}
+		}
+	}
+	for k := len(journalKinds) - 1; k >= 0; k-- {
+		for _, change := range changes {
+			if change.Kind == journalKinds[k] && change.Wanted(undo) == nil {
+				ids, err := removeJournalItem(&data, change)
+				if err != nil {
+					return err
+				}
+				touched = append(touched, ids...)
+			}
+		}
+	}
+
+	for _, id := range UniqueIds(touched) {
+		if index := FindIndexOf(id, data); index != -1 {
+			err = s.Store.PutActivity(data[index])
+		} else if FindIndexOf(id, current) != -1 {
+			err = s.Store.DeleteActivity(id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put item into data in place of the one with its id, or at its place by id.
+// Returns ids of the activities that changed.
+func putJournalItem(data *[]JsonData, change JournalChange, item json.RawMessage) ([]int, error) {
+	switch change.Kind {
+	case "activity":
+		var activity JsonData
+		err := json.Unmarshal(item, &activity)
+		if err != nil {
+			return nil, err
+		}
+		index := FindIndexOf(change.Id, *data)
+		if index == -1 {
+			activity.Projects, activity.Sessions = []Project{}, []Session{}
+			at := sort.Search(len(*data), func(i int) bool { return (*data)[i].Id > change.Id })
+			*data = append((*data)[:at], append([]JsonData{activity}, (*data)[at:]...)...)
+			return []int{change.Id}, nil
+		}
+		activity.Projects, activity.Sessions = (*data)[index].Projects, (*data)[index].Sessions
+		(*data)[index] = activity
+		return []int{change.Id}, nil
+
+	case "project":
+		var project Project
+		err := json.Unmarshal(item, &project)
+		if err != nil {
+			return nil, err
+		}
+		index := FindIndexOf(change.ParentId, *data)
+		if index == -1 {
+			return nil, fmt.Errorf("activity %d of %s is gone", change.ParentId, change.Name())
+		}
+		activity := &(*data)[index]
+		projectIndex := FindProjectIn(change.Id, *activity)
+		if projectIndex == -1 {
+			project.Tasks = []Task{}
+			at := sort.Search(len(activity.Projects), func(i int) bool { return activity.Projects[i].Id > change.Id })
+			activity.Projects = append(activity.Projects[:at], append([]Project{project}, activity.Projects[at:]...)...)
+			return []int{activity.Id}, nil
+		}
+		project.Tasks = activity.Projects[projectIndex].Tasks
+		activity.Projects[projectIndex] = project
+		return []int{activity.Id}, nil
+
+	case "task":
+		var task Task
+		err := json.Unmarshal(item, &task)
+		if err != nil {
+			return nil, err
+		}
+		activityIndex, projectIndex, ok := FindProject(change.ParentId, *data)
+		if !ok {
+			return nil, fmt.Errorf("project %d of %s is gone", change.ParentId, change.Name())
+		}
+		project := &(*data)[activityIndex].Projects[projectIndex]
+		taskIndex := FindTaskIn(change.Id, *project)
+		if taskIndex == -1 {
+			at := sort.Search(len(project.Tasks), func(i int) bool { return project.Tasks[i].Id > change.Id })
+			project.Tasks = append(project.Tasks[:at], append([]Task{task}, project.Tasks[at:]...)...)
+		} else {
+			task.Pomodoros = project.Tasks[taskIndex].Pomodoros
+			project.Tasks[taskIndex] = task
+		}
+		return []int{(*data)[activityIndex].Id}, nil
+
+	case "session":
+		var session Session
+		err := json.Unmarshal(item, &session)
+		if err != nil {
+			return nil, err
+		}
+
+		// Session may move to another activity
+		ids, err := removeJournalItem(data, change)
+		if err != nil && err != ErrNotFound {
+			return nil, err
+		}
+		index := FindIndexOf(session.ActivityId, *data)
+		if index == -1 {
+			return nil, fmt.Errorf("activity %d of %s is gone", session.ActivityId, change.Name())
+		}
+		activity := &(*data)[index]
+		at := sort.Search(len(activity.Sessions), func(i int) bool { return activity.Sessions[i].Id > change.Id })
+		activity.Sessions = append(activity.Sessions[:at], append([]Session{session}, activity.Sessions[at:]...)...)
+		return append(ids, activity.Id), nil
+	}
+	return nil, fmt.Errorf("unknown journal change '%s'", change.Kind)
+}
+
+// Remove item with the id of change from data, parents only once their children are gone.
+// Returns ids of the activities that changed.
+func removeJournalItem(data *[]JsonData, change JournalChange) ([]int, error) {
+	switch change.Kind {
+	case "activity":
+		index := FindIndexOf(change.Id, *data)
+		if index == -1 {
+			return nil, ErrNotFound
+		}
+		if len((*data)[index].Projects) > 0 || len((*data)[index].Sessions) > 0 {
+			return nil, fmt.Errorf("%s has projects or time added since", change.Name())
+		}
+		*data = append((*data)[:index], (*data)[index+1:]...)
+		return []int{change.Id}, nil
+
+	case "project":
+		activityIndex, projectIndex, ok := FindProject(change.Id, *data)
+		if !ok {
+			return nil, ErrNotFound
+		}
+		activity := &(*data)[activityIndex]
+		if len(activity.Projects[projectIndex].Tasks) > 0 || HasSessionsOf(*activity, &change.Id, nil) {
+			return nil, fmt.Errorf("%s has tasks or time added since", change.Name())
+		}
+		activity.Projects = append(activity.Projects[:projectIndex], activity.Projects[projectIndex+1:]...)
+		return []int{activity.Id}, nil
+
+	case "task":
+		activityIndex, projectIndex, taskIndex, ok := FindTask(change.Id, *data)
+		if !ok {
+			return nil, ErrNotFound
+		}
+		if HasSessionsOf((*data)[activityIndex], nil, &change.Id) {
+			return nil, fmt.Errorf("%s has time added since", change.Name())
+		}
+		project := &(*data)[activityIndex].Projects[projectIndex]
+		project.Tasks = append(project.Tasks[:taskIndex], project.Tasks[taskIndex+1:]...)
+		return []int{(*data)[activityIndex].Id}, nil
+
+	case "session":
+		activityIndex, sessionIndex, ok := FindSession(change.Id, *data)
+		if !ok {
+			return nil, ErrNotFound
+		}
+		activity := &(*data)[activityIndex]
+		activity.Sessions = append(activity.Sessions[:sessionIndex], activity.Sessions[sessionIndex+1:]...)
+		return []int{activity.Id}, nil
+	}
+	return nil, fmt.Errorf("unknown journal change '%s'", change.Kind)
+}
+
+// Undo or redo with the store of the app, it must keep a journal
+func ReplayJournal(count int, undo bool) ([]JournalEntry, error) {
+	store, ok := app.Store.(*JournalStore)
+	if !ok {
+		return nil, errors.New("store has no journal")
+	}
+	if undo {
+		return store.Undo(count)
+	}
+	return store.Redo(count)
+}
+
+/*<=================================================== Small Help functions ===================================================>*/
+
+// Activities with ids in data
+func PickActivities(data []JsonData, ids []int) []JsonData {
+	picked := []JsonData{}
+	for _, id := range ids {
+		if index := FindIndexOf(id, data); index != -1 {
+			picked = append(picked, data[index])
+		}
+	}
+	return picked
+}
+
+// What changed from before to after, matched by kind and id
+func DiffActivities(before []JsonData, after []JsonData) []JournalChange {
+	old := map[string]JournalChange{}
+	for _, item := range JournalItems(before) {
+		old[item.Key()] = item
+	}
+
+	changes := []JournalChange{}
+	seen := map[string]bool{}
+	for _, item := range JournalItems(after) {
+		seen[item.Key()] = true
+		was := old[item.Key()]
+		if !SameJson(was.After, item.After) {
+			changes = append(changes, JournalChange{Kind: item.Kind, Id: item.Id, ParentId: item.ParentId, Before: was.After, After: item.After})
+		}
+	}
+	for _, item := range JournalItems(before) {
+		if !seen[item.Key()] {
+			changes = append(changes, JournalChange{Kind: item.Kind, Id: item.Id, ParentId: item.ParentId, Before: item.After})
+		}
+	}
+	return changes
+}
+
+// Every activity, project, task and session of data on its own, kept in After
+func JournalItems(data []JsonData) []JournalChange {
+	items := []JournalChange{}
+	add := func(kind string, id int, parentId int, value interface{}) {
+		dataBytes, err := json.Marshal(value)
+		if err == nil {
+			items = append(items, JournalChange{Kind: kind, Id: id, ParentId: parentId, After: dataBytes})
+		}
+	}
+
+	for _, activity := range data {
+		alone := activity
+		alone.Projects, alone.Sessions = nil, nil
+		add("activity", activity.Id, 0, alone)
+
+		for _, project := range activity.Projects {
+			alone := project
+			alone.Tasks = nil
+			add("project", project.Id, activity.Id, alone)
+
+			// Pomodoros are counted past the journal, so they are left out of it
+			for _, task := range project.Tasks {
+				task.Pomodoros = 0
+				add("task", task.Id, project.Id, task)
+			}
+		}
+
+		// Names are looked up from the ids, a rename doesn't change sessions
+		for _, session := range activity.Sessions {
+			session.Project, session.Task = "", ""
+			add("session", session.Id, activity.Id, session)
+		}
+	}
+	return items
+}
+
+// Activity has sessions of the project or the task with the id that is given
+func HasSessionsOf(activity JsonData, projectId *int, taskId *int) bool {
+	for _, session := range activity.Sessions {
+		if projectId != nil && session.ProjectId != nil && *session.ProjectId == *projectId {
+			return true
+		}
+		if taskId != nil && session.TaskId != nil && *session.TaskId == *taskId {
+			return true
+		}
+	}
+	return false
+}
+
+// Kind and id, unique in all data
+func (c JournalChange) Key() string {
+	return fmt.Sprint(c.Kind, " ", c.Id)
+}
+
+// Item as it is before undo or redo
+func (c JournalChange) Now(undo bool) json.RawMessage {
+	if undo {
+		return c.After
+	}
+	return c.Before
+}
+
+// Item as undo or redo puts it, nil if it is removed
+func (c JournalChange) Wanted(undo bool) json.RawMessage {
+	if undo {
+		return c.Before
+	}
+	return c.After
+}
+
+// Kind and name for messages: project 'api'
+func (c JournalChange) Name() string {
+	var named struct {
+		Activity string `json:"activity"`
+		Name     string `json:"name"`
+	}
+	item := c.After
+	if item == nil {
+		item = c.Before
+	}
+	json.Unmarshal(item, &named)
+
+	switch {
+	case c.Kind == "activity":
+		return "'" + named.Activity + "'"
+	case named.Name != "":
+		return c.Kind + " '" + named.Name + "'"
+	}
+	return fmt.Sprint(c.Kind, " ", c.Id)
+}
+
+// Same json, however it is indented. Nothing is the same as nothing only.
+func SameJson(a json.RawMessage, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var aBuffer, bBuffer bytes.Buffer
+	aErr := json.Compact(&aBuffer, a)
+	bErr := json.Compact(&bBuffer, b)
+	return aErr == nil && bErr == nil && bytes.Equal(aBuffer.Bytes(), bBuffer.Bytes())
+}
+
+// Copy of activities that can be changed without changing data
+func CopyActivities(data []JsonData) []JsonData {
+	copied := []JsonData{}
+	for _, activity := range data {
+		projects := []Project{}
+		for _, project := range activity.Projects {
+			project.Tasks = append([]Task{}, project.Tasks...)
+			projects = append(projects, project)
+		}
+		activity.Projects = projects
+		activity.Sessions = append([]Session{}, activity.Sessions...)
+		copied = append(copied, activity)
+	}
+	return copied
+}
+
+func UniqueIds(ids []int) []int {
+	unique := []int{}
+	for _, id := range ids {
+		found := false
+		for _, seen := range unique {
+			found = found || seen == id
+		}
+		if !found {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// Name of activity with id for messages
+func ActivityName(id int, data []JsonData) string {
+	if index := FindIndexOf(id, data); index != -1 {
+		return data[index].Activity
+	}
+	return fmt.Sprint(id)
+}
+
+func ArchiveVerb(archived bool) string {
+	if archived {
+		return "archive"
+	}
+	return "unarchive"
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How long to wait for another running instance to release the data file
+var lockTimeout = 5 * time.Second
+
+var ErrLocked = errors.New("data file is locked by another running instance")
+
+// Advisory lock held on <file>.lock while data is read, changed and written back.
+// The lock lives in its own file because the data file is replaced on every save.
+type FileLock struct {
+	file *os.File
+}
+
+// Lock the file, retry until lockTimeout is over
+func LockFile(filename string) (*FileLock, error) {
+	lockname := filename + ".lock"
+
+	file, err := os.OpenFile(lockname, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			return &FileLock{file: file}, nil
+		}
+
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("%w (%s)", ErrLocked, lockname)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Release the lock
+func (l *FileLock) Unlock() error {
+	err := unlock(l.file)
+	closeErr := l.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Write data to a temp file next to filename and rename it over filename,
+// so a crash never leaves a half written file behind
+func WriteFileAtomic(filename string, dataBytes []byte, perm os.FileMode) error {
+	temp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// Remove temp file if anything goes wrong
+	ok := false
+	defer func() {
+		if !ok {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	if _, err = temp.Write(dataBytes); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(temp.Name(), perm); err != nil {
+		return err
+	}
+	if err = os.Rename(temp.Name(), filename); err != nil {
+		return err
+	}
+
+	ok = true
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time that was not taken with the timer is logged afterwards as a session.
+// Logged sessions can't be in the future or overlap saved sessions or the running timer.
+
+// Save session for activity given by name, short name or id
+func LogSession(name string, project string, task string, start time.Time, end time.Time, pause time.Duration) (Session, error) {
+	data := OpenAndGetDataFromJson()
+
+	activity, project, task, err := ResolveTimerTarget(data, name, project, task)
+	if err != nil {
+		return Session{}, err
+	}
+
+	session := Session{ActivityId: activity.Id, Project: project, Task: task, Start: start, End: end, PauseSeconds: Seconds(pause)}
+	err = CheckLoggedSession(data, session, -1)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return app.Store.AddSession(session)
+}
+
+// Tell what was logged
+func PrintLoggedSession(session Session) {
+	Feedback("<< Logged ", FormatMinutes(SessionMinutes(session)), "", false)
+	Feedback(" of '", ActivityName(session.ActivityId, OpenAndGetDataFromJson()), "'", false)
+	if session.Project != "" {
+		Feedback(" / ", session.Project, "", false)
+	}
+	if session.Task != "" {
+		Feedback(" / ", session.Task, "", false)
+	}
+	Feedback(" (", session.Start.Format("02.01.2006 15:04")+" - "+session.End.Format("15:04"), ") >>\n", false)
+}
+
+// Session must end after it starts, not in the future, and overlap nothing.
+// Session with id ignore is the one being changed, -1 for new sessions.
+func CheckLoggedSession(data []JsonData, session Session, ignore int) error {
+	if !session.End.After(session.Start) {
+		return errors.New("end must be after start")
+	}
+	if session.End.After(app.Now()) {
+		return errors.New("end is in the future")
+	}
+	if session.PauseSeconds < 0 || SessionDuration(session) < 0 {
+		return errors.New("pause is longer than the session")
+	}
+
+	err := CheckOverlap(data, session.Start, session.End, ignore)
+	if err != nil {
+		return err
+	}
+
+	// Running timer takes everything from its start till now
+	timer, err := LoadTimer()
+	if err != nil {
+		return err
+	}
+	if timer != nil && session.End.After(timer.Start) {
+		return fmt.Errorf("overlaps the running timer of '%s' started %s", timer.Activity, timer.Start.Format("02.01.2006 15:04"))
+	}
+	return nil
+}
+
+// Time from start to end can't overlap a saved session, touching ends are fine
+func CheckOverlap(data []JsonData, start time.Time, end time.Time, ignore int) error {
+	for _, activity := range data {
+		for _, session := range activity.Sessions {
+			if session.Id == ignore {
+				continue
+			}
+			if start.Before(session.End) && session.Start.Before(end) {
+				return fmt.Errorf("overlaps '%s' %s - %s", activity.Activity,
+					session.Start.Format("02.01.2006 15:04"), session.End.Format("15:04"))
+			}
+		}
+	}
+	return nil
+}
+
+// Parse start as HH:MM today, dd.mm.yyyy HH:MM or 20m ago
+func ParseLogTime(answer string, now time.Time) (time.Time, error) {
+	if ago := strings.TrimSuffix(answer, " ago"); ago != answer {
+		duration, err := ParseLogDuration(ago)
+		return now.Add(-duration), err
+	}
+
+	start, err := time.ParseInLocation("02.01.2006 15:04", answer, now.Location())
+	if err == nil {
+		return start, nil
+	}
+
+	clock, err := time.ParseInLocation("15:04", answer, now.Location())
+	if err != nil {
+		return start, errors.New("use HH:MM, dd.mm.yyyy HH:MM or 20m ago")
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location()), nil
+}
+
+// Parse end like ParseEndTime, 20m ago works too
+func ParseLogEnd(answer string, start time.Time, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(answer, " ago") {
+		return ParseLogTime(answer, now)
+	}
+	return ParseEndTime(answer, start)
+}
+
+// Words after a number that are minutes or hours, nothing else is a unit of logged time
+var durationUnits = map[string]string{
+	"m": "m", "min": "m", "mins": "m", "minute": "m", "minutes": "m",
+	"h": "h", "hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+}
+
+// Parse 1h30m, 90m, 90 or 20 minutes, plain numbers are minutes.
+// Only hours and minutes are taken, 20s or 20 seconds is refused.
+func ParseLogDuration(answer string) (time.Duration, error) {
+	answer = strings.TrimSpace(answer)
+	if minutes, err := strconv.Atoi(answer); err == nil {
+		answer = fmt.Sprint(minutes, "m")
+	}
+
+	wrong := fmt.Errorf("'%s' is not a duration, use 1h30m, 90m or 20 minutes", answer)
+
+	fields := strings.Fields(answer)
+	if len(fields) == 2 {
+		unit, ok := durationUnits[strings.ToLower(fields[1])]
+		if !ok {
+			return 0, wrong
+		}
+		answer = fields[0] + unit
+	}
+
+	// Units of time.ParseDuration other than h and m are left out
+	if strings.Trim(answer, "0123456789.hm") != "" {
+		return 0, wrong
+	}
+
+	duration, err := time.ParseDuration(answer)
+	if err != nil || duration <= 0 {
+		return 0, wrong
+	}
+	return duration, nil
+}
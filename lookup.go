@@ -0,0 +1,157 @@
+package main
+
+import "fmt"
+
+// Activities, projects and tasks are found by their id, never by their position.
+// Ids stay the same when something else is deleted.
+
+// Find index of activity with id, -1 if not found
+func FindIndexOf(element int, data []JsonData) int {
+	for index, Struct := range data {
+		if element == Struct.Id {
+			return index
+		}
+	}
+	return -1 //not found.
+}
+
+// Find project by id, returns index of its activity and its index in the activity
+func FindProject(id int, data []JsonData) (int, int, bool) {
+	for activityIndex, activity := range data {
+		if projectIndex := FindProjectIn(id, activity); projectIndex != -1 {
+			return activityIndex, projectIndex, true
+		}
+	}
+	return -1, -1, false
+}
+
+// Find task by id, returns indexes of its activity, project and the task
+func FindTask(id int, data []JsonData) (int, int, int, bool) {
+	for activityIndex, activity := range data {
+		for projectIndex, project := range activity.Projects {
+			if taskIndex := FindTaskIn(id, project); taskIndex != -1 {
+				return activityIndex, projectIndex, taskIndex, true
+			}
+		}
+	}
+	return -1, -1, -1, false
+}
+
+// Index of project with id in the activity, -1 if the activity has no such project
+func FindProjectIn(id int, activity JsonData) int {
+	for index, project := range activity.Projects {
+		if project.Id == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// Index of task with id in the project, -1 if the project has no such task
+func FindTaskIn(id int, project Project) int {
+	for index, task := range project.Tasks {
+		if task.Id == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// Find session by id, returns index of its activity and its index in the activity
+func FindSession(id int, data []JsonData) (int, int, bool) {
+	for activityIndex, activity := range data {
+		for sessionIndex, session := range activity.Sessions {
+			if session.Id == id {
+				return activityIndex, sessionIndex, true
+			}
+		}
+	}
+	return -1, -1, false
+}
+
+// Find project of the activity by name or id
+func FindProjectNamed(name string, activity JsonData) (Project, bool) {
+	for _, project := range activity.Projects {
+		if project.Name == name || fmt.Sprint(project.Id) == name {
+			return project, true
+		}
+	}
+	return Project{}, false
+}
+
+// Find task of the project by name or id
+func FindTaskNamed(name string, project Project) (Task, bool) {
+	for _, task := range project.Tasks {
+		if task.Name == name || fmt.Sprint(task.Id) == name {
+			return task, true
+		}
+	}
+	return Task{}, false
+}
+
+// Ids of the project and task with these names in the activity, nil for no project or task
+func TargetIds(activity JsonData, project string, task string) (*int, *int, error) {
+	if project == "" {
+		return nil, nil, nil
+	}
+	for _, value := range activity.Projects {
+		if value.Name != project {
+			continue
+		}
+		projectId := value.Id
+		if task == "" {
+			return &projectId, nil, nil
+		}
+		for _, each := range value.Tasks {
+			if each.Name == task {
+				taskId := each.Id
+				return &projectId, &taskId, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("no task '%s' in project '%s'", task, project)
+	}
+	return nil, nil, fmt.Errorf("no project '%s' in '%s'", project, activity.Activity)
+}
+
+// Names of the project and task with these ids in the activity, empty for none
+func TargetNames(activity JsonData, projectId *int, taskId *int) (string, string) {
+	if projectId == nil {
+		return "", ""
+	}
+	index := FindProjectIn(*projectId, activity)
+	if index == -1 {
+		return "", ""
+	}
+	project := activity.Projects[index]
+	if taskId == nil {
+		return project.Name, ""
+	}
+	if taskIndex := FindTaskIn(*taskId, project); taskIndex != -1 {
+		return project.Name, project.Tasks[taskIndex].Name
+	}
+	return project.Name, ""
+}
+
+// Activities that are not archived, their archived projects left out
+func VisibleActivities(data []JsonData) []JsonData {
+	visible := []JsonData{}
+	for _, activity := range data {
+		if activity.Archived {
+			continue
+		}
+		activity.Projects = VisibleProjects(activity)
+		visible = append(visible, activity)
+	}
+	return visible
+}
+
+// Projects of the activity that are not archived
+func VisibleProjects(activity JsonData) []Project {
+	visible := []Project{}
+	for _, project := range activity.Projects {
+		if !project.Archived {
+			visible = append(visible, project)
+		}
+	}
+	return visible
+}
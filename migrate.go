@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Everything saved in the data file
+type DataFile struct {
+	Version    int        `json:"version"`
+	NextIds    NextIds    `json:"next_ids"`
+	Activities []JsonData `json:"activities"`
+}
+
+// Next free ids, ids are never reused after something is deleted
+type NextIds struct {
+	Activity int `json:"activity"`
+	Project  int `json:"project"`
+	Task     int `json:"task"`
+	Session  int `json:"session"`
+}
+
+// Upgrade decoded json one version up
+type Migration func(doc interface{}) (interface{}, error)
+
+// migrations[i] upgrades the data file from version i+1 to i+2.
+// Add new steps to the end, never change old ones.
+var migrations = []Migration{
+	MigrateBareArray,
+	MigrateStableIds,
+	MigratePauseSeconds,
+	MigrateTaskStatus,
+	MigrateSessionIds,
+}
+
+// Version written by this program
+var SchemaVersion = len(migrations) + 1
+
+// Version 1 -> 2: bare array of activities is wrapped in a versioned envelope
+func MigrateBareArray(doc interface{}) (interface{}, error) {
+	activities, ok := doc.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("version 1 data must be an array")
+	}
+	return map[string]interface{}{"activities": activities}, nil
+}
+
+// Version 2 -> 3: projects and tasks get ids, tasks become objects with a name
+func MigrateStableIds(doc interface{}) (interface{}, error) {
+	envelope, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("version 2 data must be an object")
+	}
+	activities, _ := envelope["activities"].([]interface{})
+
+	projectId, taskId := 0, 0
+	for _, a := range activities {
+		activity, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("activity must be an object")
+		}
+		projects, _ := activity["projects"].([]interface{})
+
+		for _, p := range projects {
+			project, ok := p.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("project must be an object")
+			}
+			project["id"] = projectId
+			projectId++
+
+			tasks, _ := project["tasks"].([]interface{})
+			objects := []interface{}{}
+			for _, t := range tasks {
+				name, ok := t.(string)
+				if !ok {
+					return nil, fmt.Errorf("task must be a string")
+				}
+				objects = append(objects, map[string]interface{}{"id": taskId, "name": name})
+				taskId++
+			}
+			project["tasks"] = objects
+		}
+	}
+	return envelope, nil
+}
+
+// Version 3 -> 4: session pause is kept in seconds instead of minutes
+func MigratePauseSeconds(doc interface{}) (interface{}, error) {
+	envelope, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("version 3 data must be an object")
+	}
+	activities, _ := envelope["activities"].([]interface{})
+
+	for _, a := range activities {
+		activity, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("activity must be an object")
+		}
+		sessions, _ := activity["sessions"].([]interface{})
+
+		for _, s := range sessions {
+			session, ok := s.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("session must be an object")
+			}
+			pause, _ := session["pause"].(float64)
+			session["pause_seconds"] = int(pause * 60)
+			delete(session, "pause")
+		}
+	}
+	return envelope, nil
+}
+
+// Version 4 -> 5: tasks get a status and a priority, all tasks before are todo
+func MigrateTaskStatus(doc interface{}) (interface{}, error) {
+	envelope, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("version 4 data must be an object")
+	}
+	activities, _ := envelope["activities"].([]interface{})
+
+	for _, a := range activities {
+		activity, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("activity must be an object")
+		}
+		projects, _ := activity["projects"].([]interface{})
+
+		for _, p := range projects {
+			project, ok := p.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("project must be an object")
+			}
+			tasks, _ := project["tasks"].([]interface{})
+
+			for _, t := range tasks {
+				task, ok := t.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("task must be an object")
+				}
+				task["status"] = TaskTodo
+				task["priority"] = 0
+			}
+		}
+	}
+	return envelope, nil
+}
+
+// Version 5 -> 6: sessions keep the ids of their project and task instead of the names.
+// A name no project or task has anymore is dropped, the time stays with the activity.
+func MigrateSessionIds(doc interface{}) (interface{}, error) {
+	envelope, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("version 5 data must be an object")
+	}
+	activities, _ := envelope["activities"].([]interface{})
+
+	for _, a := range activities {
+		activity, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("activity must be an object")
+		}
+
+		// Ids by project name and by project and task name, the first one of a name wins
+		projectIds, taskIds := map[string]interface{}{}, map[[2]string]interface{}{}
+		projects, _ := activity["projects"].([]interface{})
+		for _, p := range projects {
+			project, ok := p.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("project must be an object")
+			}
+			name, _ := project["name"].(string)
+			if _, ok := projectIds[name]; !ok {
+				projectIds[name] = project["id"]
+			}
+
+			tasks, _ := project["tasks"].([]interface{})
+			for _, t := range tasks {
+				task, ok := t.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("task must be an object")
+				}
+				taskName, _ := task["name"].(string)
+				if _, ok := taskIds[[2]string{name, taskName}]; !ok {
+					taskIds[[2]string{name, taskName}] = task["id"]
+				}
+			}
+		}
+
+		sessions, _ := activity["sessions"].([]interface{})
+		for _, s := range sessions {
+			session, ok := s.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("session must be an object")
+			}
+			project, _ := session["project"].(string)
+			task, _ := session["task"].(string)
+			if id, ok := projectIds[project]; ok && project != "" {
+				session["project_id"] = id
+				if id, ok := taskIds[[2]string{project, task}]; ok && task != "" {
+					session["task_id"] = id
+				}
+			}
+			delete(session, "project")
+			delete(session, "task")
+		}
+	}
+	return envelope, nil
+}
+
+// Find version of the data file. Files without a version are the bare array of version 1.
+func DataVersion(file []byte) (int, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(file), []byte("[")) {
+		return 1, nil
+	}
+
+	var envelope struct {
+		Version int `json:"version"`
+	}
+	err := json.Unmarshal(file, &envelope)
+	if err != nil {
+		return 0, err
+	}
+	if envelope.Version < 1 {
+		return 0, fmt.Errorf("data file has no version")
+	}
+	return envelope.Version, nil
+}
+
+// Upgrade data file step by step to SchemaVersion
+func MigrateData(file []byte) (DataFile, int, error) {
+	data := DataFile{}
+
+	version, err := DataVersion(file)
+	if err != nil {
+		return data, 0, err
+	}
+	if version > SchemaVersion {
+		return data, version, fmt.Errorf("data file version %d is newer than this program (version %d)", version, SchemaVersion)
+	}
+
+	if version < SchemaVersion {
+		var doc interface{}
+		err = json.Unmarshal(file, &doc)
+		if err != nil {
+			return data, version, err
+		}
+
+		for v := version; v < SchemaVersion; v++ {
+			doc, err = migrations[v-1](doc)
+			if err != nil {
+				return data, version, fmt.Errorf("migrate version %d to %d: %w", v, v+1, err)
+			}
+			doc.(map[string]interface{})["version"] = v + 1
+		}
+
+		file, err = json.Marshal(doc)
+		if err != nil {
+			return data, version, err
+		}
+	}
+
+	err = json.Unmarshal(file, &data)
+	if data.Activities == nil {
+		data.Activities = []JsonData{}
+	}
+	data.RaiseNextIds()
+	data.FillSessionNames()
+	return data, version, err
+}
+
+// Move next ids past the ids in use, so a hand edited or migrated file can't give an id twice
+func (d *DataFile) RaiseNextIds() {
+	raise := func(next *int, id int) {
+		if id >= *next {
+			*next = id + 1
+		}
+	}
+
+	for _, activity := range d.Activities {
+		raise(&d.NextIds.Activity, activity.Id)
+		for _, project := range activity.Projects {
+			raise(&d.NextIds.Project, project.Id)
+			for _, task := range project.Tasks {
+				raise(&d.NextIds.Task, task.Id)
+			}
+		}
+		for _, session := range activity.Sessions {
+			raise(&d.NextIds.Session, session.Id)
+		}
+	}
+}
+
+// Sessions keep the ids of their project and task, their names are looked up for showing
+func (d *DataFile) FillSessionNames() {
+	for _, activity := range d.Activities {
+		for index, session := range activity.Sessions {
+			activity.Sessions[index].Project, activity.Sessions[index].Task = TargetNames(activity, session.ProjectId, session.TaskId)
+		}
+	}
+}
+
+// Copy data file before migrating it: data.json -> data.json.v1-20060102-150405.bak
+func BackupDataFile(filename string, version int) (string, error) {
+	file, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	backup := fmt.Sprintf("%s.v%d-%s.bak", filename, version, time.Now().Format("20060102-150405"))
+	return backup, WriteFileAtomic(backup, file, 0644)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Same coding activity in every version: project api with task docs, an hour with a 5 minute pause
+func TestMigrateData(t *testing.T) {
+	session := fmt.Sprintf(`"id": 0, "activity_id": 0, "start": "%s", "end": "%s"`,
+		testStart.Format(time.RFC3339), testStart.Add(time.Hour).Format(time.RFC3339))
+	tests := []struct {
+		name    string
+		file    string
+		version int
+		project string
+		err     bool
+	}{
+		{"bare array", `[{"id": 0, "activity": "coding", "short": "c", "projects": [{"name": "api", "tasks": ["docs"]}],
+			"sessions": [{` + session + `, "project": "api", "task": "docs", "pause": 5}]}]`, 1, "api", false},
+		{"version 2 without ids", `{"version": 2, "activities": [{"id": 0, "activity": "coding", "short": "c",
+			"projects": [{"name": "api", "tasks": ["docs"]}], "sessions": [{` + session + `, "project": "api", "task": "docs", "pause": 5}]}]}`, 2, "api", false},
+		{"version 3 pause in minutes", `{"version": 3, "activities": [{"id": 0, "activity": "coding", "short": "c",
+			"projects": [{"id": 0, "name": "api", "tasks": [{"id": 0, "name": "docs"}]}], "sessions": [{` + session + `, "project": "api", "task": "docs", "pause": 5}]}]}`, 3, "api", false},
+		{"version 4 tasks without status", `{"version": 4, "activities": [{"id": 0, "activity": "coding", "short": "c",
+			"projects": [{"id": 0, "name": "api", "tasks": [{"id": 0, "name": "docs"}]}], "sessions": [{` + session + `, "project": "api", "task": "docs", "pause_seconds": 300}]}]}`, 4, "api", false},
+		{"version 5 sessions by name", `{"version": 5, "activities": [{"id": 0, "activity": "coding", "short": "c",
+			"projects": [{"id": 0, "name": "api", "tasks": [{"id": 0, "name": "docs", "status": "todo"}]}], "sessions": [{` + session + `, "project": "api", "task": "docs", "pause_seconds": 300}]}]}`, 5, "api", false},
+		{"version 5 deleted project", `{"version": 5, "activities": [{"id": 0, "activity": "coding", "short": "c",
+			"projects": [{"id": 0, "name": "api", "tasks": [{"id": 0, "name": "docs", "status": "todo"}]}], "sessions": [{` + session + `, "project": "gone", "pause_seconds": 300}]}]}`, 5, "", false},
+		{"current version", `{"version": 6, "activities": [{"id": 0, "activity": "coding", "short": "c",
+			"projects": [{"id": 0, "name": "api", "tasks": [{"id": 0, "name": "docs", "status": "todo"}]}], "sessions": [{` + session + `, "project_id": 0, "task_id": 0, "pause_seconds": 300}]}]}`, 6, "api", false},
+		{"newer version", `{"version": 99, "activities": []}`, 99, "", true},
+		{"no version", `{"activities": []}`, 0, "", true},
+		{"not json", `nothing`, 0, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, version, err := MigrateData([]byte(test.file))
+			if version != test.version || (err != nil) != test.err {
+				t.Fatalf("version %d (%v), want %d", version, err, test.version)
+			}
+			if test.err {
+				return
+			}
+
+			if len(data.Activities) != 1 || data.NextIds != (NextIds{Activity: 1, Project: 1, Task: 1, Session: 1}) {
+				t.Fatalf("data %+v", data)
+			}
+			task := data.Activities[0].Projects[0].Tasks[0]
+			if task.Id != 0 || task.Name != "docs" || task.Status != TaskTodo {
+				t.Errorf("task %+v", task)
+			}
+			AssertSession(t, data.Activities[0].Sessions[0], testStart, testStart.Add(time.Hour), 5, test.project)
+		})
+	}
+}
+
+// Old file is copied before the upgrade and the new version is written
+func TestMigrateKeepsBackup(t *testing.T) {
+	NewTestApp(t)
+	old := []byte(`[{"id": 0, "activity": "coding", "short": "c", "projects": [], "sessions": []}]`)
+	err := ioutil.WriteFile(filename, old, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewJsonStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := filepath.Glob(filename + ".v1-*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("backups %v", backups)
+	}
+	if backup, _ := ioutil.ReadFile(backups[0]); string(backup) != string(old) {
+		t.Errorf("backup %s", backup)
+	}
+	if data := ReadDataFile(t); len(data) != 1 || data[0].Activity != "coding" {
+		t.Errorf("data %+v", data)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Pomodoro mode runs the timer in work intervals with a break after each one and a long
+// break every few of them. Breaks are pause time of the running timer, like a pause with
+// the pause command. Phases follow the clock: every check catches up with the phases that
+// passed since the one before, so nothing is lost while no ui is open.
+
+// Pomodoro of the running timer
+type Pomodoro struct {
+	// work, break or long break, ends at End
+	Phase string    `json:"phase"`
+	End   time.Time `json:"end"`
+
+	// Work intervals finished since pomodoro mode was turned on
+	Done int `json:"done"`
+}
+
+// Interval lengths in minutes, from config
+type PomodoroConfig struct {
+	Work      int `json:"work"`
+	Break     int `json:"break"`
+	LongBreak int `json:"long_break"`
+
+	// Long break instead of the break after every this many work intervals
+	LongBreakEvery int `json:"long_break_every"`
+}
+
+// Phase that began at At
+type PomodoroTransition struct {
+	Phase string
+	At    time.Time
+	Done  int
+}
+
+const (
+	PhaseWork      = "work"
+	PhaseBreak     = "break"
+	PhaseLongBreak = "long break"
+)
+
+var ErrPomodoroOn = errors.New("pomodoro mode is already on")
+var ErrPomodoroOff = errors.New("pomodoro mode is not on")
+
+// Intervals of at least a minute
+func (c PomodoroConfig) Check() error {
+	lengths := map[string]int{"work": c.Work, "break": c.Break, "long_break": c.LongBreak, "long_break_every": c.LongBreakEvery}
+	for name, length := range lengths {
+		if length < 1 {
+			return fmt.Errorf("%s must be 1 or more, not %d", name, length)
+		}
+	}
+	return nil
+}
+
+// Length of phase
+func (c PomodoroConfig) Length(phase string) time.Duration {
+	minutes := map[string]int{PhaseWork: c.Work, PhaseBreak: c.Break, PhaseLongBreak: c.LongBreak}[phase]
+	return time.Duration(minutes) * time.Minute
+}
+
+// Move timer through the phases that ended by now. A work interval that ends starts
+// a break, the pause of the timer. A break that ends stops the pause and work goes on.
+// Work doesn't end while the timer is paused by hand, resuming moves its end.
+func NextPomodoroPhases(timer *Timer, config PomodoroConfig, now time.Time) []PomodoroTransition {
+	pomodoro := timer.Pomodoro
+	if pomodoro == nil {
+		return nil
+	}
+
+	transitions := []PomodoroTransition{}
+	for !now.Before(pomodoro.End) {
+		at := pomodoro.End
+
+		if pomodoro.Phase == PhaseWork {
+			if timer.PausedAt != nil {
+				break
+			}
+
+			pomodoro.Done++
+			pomodoro.Phase = PhaseBreak
+			if pomodoro.Done%config.LongBreakEvery == 0 {
+				pomodoro.Phase = PhaseLongBreak
+			}
+			timer.PausedAt = &at
+		} else {
+			timer.PauseSeconds = Seconds(TimerPause(*timer, at))
+			timer.PausedAt = nil
+			pomodoro.Phase = PhaseWork
+		}
+
+		pomodoro.End = at.Add(config.Length(pomodoro.Phase))
+		transitions = append(transitions, PomodoroTransition{Phase: pomodoro.Phase, At: at, Done: pomodoro.Done})
+	}
+	return transitions
+}
+
+// Catch up with the phases of the running timer, finished work intervals are counted
+// for the task of the timer. Timer is nil if none is running.
+func AdvancePomodoro(now time.Time) (*Timer, []PomodoroTransition, error) {
+	var advanced *Timer
+	var transitions []PomodoroTransition
+	err := WithTimerLock(func() error {
+		timer, err := LoadTimer()
+		if err != nil || timer == nil {
+			return err
+		}
+		advanced = timer
+
+		transitions, err = catchUpPomodoro(timer, now)
+		if err != nil || len(transitions) == 0 {
+			return err
+		}
+		return SaveTimer(*timer)
+	})
+	return advanced, transitions, err
+}
+
+// Phases that passed, with the pomodoros counted. Timer is changed, not saved.
+func catchUpPomodoro(timer *Timer, now time.Time) ([]PomodoroTransition, error) {
+	transitions := NextPomodoroPhases(timer, app.Pomodoro, now)
+
+	finished := 0
+	for _, transition := range transitions {
+		if transition.Phase != PhaseWork {
+			finished++
+		}
+	}
+	return transitions, CountPomodoros(*timer, finished)
+}
+
+// Add finished work intervals to the task of the timer, nothing is counted without a task.
+// Counts go past the journal, undo is for what was done by hand.
+func CountPomodoros(timer Timer, finished int) error {
+	if finished == 0 || timer.TaskId == nil {
+		return nil
+	}
+
+	store := app.Store
+	if journal, ok := store.(*JournalStore); ok {
+		store = journal.Store
+	}
+
+	return WithDataLock(func() error {
+		data, err := store.Activities()
+		if err != nil {
+			return err
+		}
+		activityIndex, projectIndex, taskIndex, ok := FindTask(*timer.TaskId, data)
+		if !ok {
+			return nil
+		}
+		task := data[activityIndex].Projects[projectIndex].Tasks[taskIndex]
+		task.Pomodoros += finished
+		return store.UpdateTask(task)
+	})
+}
+
+// Turn pomodoro mode on, the first work interval starts now and a pause that goes on ends
+func StartPomodoro(now time.Time) (Timer, error) {
+	return changeTimer(func(timer *Timer) error {
+		if timer.Pomodoro != nil {
+			return ErrPomodoroOn
+		}
+		StartPomodoroOf(timer, now)
+		return nil
+	})
+}
+
+// First work interval of timer starts now
+func StartPomodoroOf(timer *Timer, now time.Time) {
+	if timer.PausedAt != nil {
+		timer.PauseSeconds = Seconds(TimerPause(*timer, now))
+		timer.PausedAt = nil
+	}
+	timer.Pomodoro = &Pomodoro{Phase: PhaseWork, End: now.Add(app.Pomodoro.Length(PhaseWork))}
+}
+
+// Turn pomodoro mode off, a break that goes on ends now
+func StopPomodoro(now time.Time) (Timer, error) {
+	return changeTimer(func(timer *Timer) error {
+		if timer.Pomodoro == nil {
+			return ErrPomodoroOff
+		}
+		if timer.Pomodoro.Phase != PhaseWork {
+			timer.PauseSeconds = Seconds(TimerPause(*timer, now))
+			timer.PausedAt = nil
+		}
+		timer.Pomodoro = nil
+		return nil
+	})
+}
+
+// Turn pomodoro mode on or off
+func TogglePomodoro(now time.Time) (Timer, error) {
+	timer, err := LoadTimer()
+	if err != nil {
+		return Timer{}, err
+	}
+	if timer != nil && timer.Pomodoro != nil {
+		return StopPomodoro(now)
+	}
+	return StartPomodoro(now)
+}
+
+// Resuming during work moves the end of the interval by the pause,
+// resuming during a break ends it and the next work interval starts now
+func ResumePomodoro(timer *Timer, now time.Time) {
+	pomodoro := timer.Pomodoro
+	if pomodoro == nil || timer.PausedAt == nil {
+		return
+	}
+
+	if pomodoro.Phase == PhaseWork {
+		pomodoro.End = pomodoro.End.Add(now.Sub(*timer.PausedAt))
+		return
+	}
+	pomodoro.Phase = PhaseWork
+	pomodoro.End = now.Add(app.Pomodoro.Length(PhaseWork))
+}
+
+// Notice for the start of a phase
+func PomodoroNotice(transition PomodoroTransition) string {
+	at := transition.At.Format("15:04")
+	switch transition.Phase {
+	case PhaseBreak:
+		return fmt.Sprintf("Pomodoro %d done at %s, take a %d minute break", transition.Done, at, app.Pomodoro.Break)
+	case PhaseLongBreak:
+		return fmt.Sprintf("Pomodoro %d done at %s, take a long break of %d minutes", transition.Done, at, app.Pomodoro.LongBreak)
+	}
+	return fmt.Sprintf("Break over at %s, back to work for %d minutes", at, app.Pomodoro.Work)
+}
+
+// Phase and its end for status lines: work till 10:25 (2 done)
+func PomodoroStatus(pomodoro Pomodoro) string {
+	return fmt.Sprintf("%s till %s (%d done)", pomodoro.Phase, pomodoro.End.Format("15:04"), pomodoro.Done)
+}
+
+// Phases told about already. The commandline and its watcher both tell about
+// phases, the one that comes first does.
+var announced struct {
+	sync.Mutex
+	until time.Time
+}
+
+// Ring the bell and print a notice for every phase not told about yet
+func AnnouncePomodoro(transitions []PomodoroTransition) {
+	announced.Lock()
+	defer announced.Unlock()
+
+	for _, transition := range transitions {
+		if !transition.At.After(announced.until) {
+			continue
+		}
+		announced.until = transition.At
+
+		app.Out.Bell()
+		Feedback("\n<< ", PomodoroNotice(transition), " >>\n", transition.Phase == PhaseWork)
+	}
+}
+
+// Tell about phases while the commandline waits for input. Nothing is saved here,
+// the next command catches up with the phases.
+func WatchPomodoro() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		timer, err := LoadTimer()
+		if err != nil || timer == nil || timer.Pomodoro == nil {
+			continue
+		}
+		AnnouncePomodoro(NextPomodoroPhases(timer, app.Pomodoro, app.Now()))
+	}
+}
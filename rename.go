@@ -0,0 +1,101 @@
+package main
+
+// New names get the same checks as when adding. Keeping the old name is always fine,
+// a name only clashes with other activities, projects or tasks. Nothing is saved
+// (or journaled) when the names stay the same.
+
+// Check new activity or short name, the activity's own names don't count as taken
+func CheckActivityRename(activity JsonData, name string, data []JsonData) error {
+	if name == activity.Activity || name == activity.Short {
+		return nil
+	}
+
+	others := []JsonData{}
+	for _, value := range data {
+		if value.Id != activity.Id {
+			others = append(others, value)
+		}
+	}
+	return CheckActivityName(name, others)
+}
+
+// Check new activity and short name, each one on its own
+func CheckActivityRenames(activity JsonData, name string, short string, data []JsonData) error {
+	for _, value := range []string{name, short} {
+		err := CheckActivityRename(activity, value, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check new project name
+func CheckProjectRename(project Project, name string, data []JsonData) error {
+	if name == project.Name {
+		return nil
+	}
+	return CheckProjectName(name, data)
+}
+
+// Check new task name, tasks only have to differ in their project
+func CheckTaskRename(task Task, project Project, name string) error {
+	if name == task.Name {
+		return nil
+	}
+	return CheckTaskName(name, project)
+}
+
+// Rename activity, the running timer shows the new name
+func RenameActivity(activity JsonData, name string, short string) error {
+	if name == activity.Activity && short == activity.Short {
+		return nil
+	}
+
+	err := app.Store.RenameActivity(activity.Id, name, short)
+	if err != nil {
+		return err
+	}
+
+	return UpdateTimer(func(timer *Timer) {
+		if timer.ActivityId == activity.Id {
+			timer.Activity = name
+		}
+	})
+}
+
+// Rename project, its sessions and the running timer get the new name
+func RenameProject(activity JsonData, project Project, name string) error {
+	if name == project.Name {
+		return nil
+	}
+
+	err := app.Store.RenameProject(project.Id, name)
+	if err != nil {
+		return err
+	}
+
+	return UpdateTimer(func(timer *Timer) {
+		if timer.ProjectId != nil && *timer.ProjectId == project.Id {
+			timer.Project = name
+		}
+	})
+}
+
+// Rename task, its sessions and the running timer get the new name
+func RenameTask(activity JsonData, project Project, task Task, name string) error {
+	if name == task.Name {
+		return nil
+	}
+
+	err := app.Store.RenameTask(task.Id, name)
+	if err != nil {
+		return err
+	}
+
+	return UpdateTimer(func(timer *Timer) {
+		if timer.TaskId != nil && *timer.TaskId == task.Id {
+			timer.Task = name
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Time spent by activity, project and task in a date range.
+// Seconds are added up, minutes are rounded from them by the rounding policy.
+type Report struct {
+	From       time.Time    `json:"from"`
+	To         time.Time    `json:"to"`
+	Minutes    int          `json:"minutes"`
+	Seconds    int          `json:"seconds"`
+	Activities []ReportItem `json:"activities"`
+}
+
+// One line of the report with the lines below it
+type ReportItem struct {
+	Name    string       `json:"name"`
+	Minutes int          `json:"minutes"`
+	Seconds int          `json:"seconds"`
+	Items   []ReportItem `json:"items,omitempty"`
+}
+
+// Shown for sessions saved without a project
+var noProject = "(no project)"
+
+// tm report [today|week|month] [--from YYYY-MM-DD] [--to YYYY-MM-DD]
+func CmdReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "first day YYYY-MM-DD")
+	toFlag := fs.String("to", "", "last day YYYY-MM-DD")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: tm " + subcommands["report"].Usage)
+	}
+
+	period := "week"
+	if len(positional) == 1 {
+		period = positional[0]
+	}
+
+	from, to, err := ReportRange(period, *fromFlag, *toFlag, app.Now())
+	if err != nil {
+		return err
+	}
+
+	PrintReport(BuildReport(OpenAndGetDataFromJson(), from, to))
+	return nil
+}
+
+// Find start and end of period. --from and --to override the period,
+// a missing --to means up to today.
+func ReportRange(period string, fromDay string, toDay string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var from, to time.Time
+	switch period {
+	case "today", "day":
+		from, to = today, today.AddDate(0, 0, 1)
+	case "week":
+		// Week starts on monday
+		from = today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		to = from.AddDate(0, 0, 7)
+	case "month":
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		to = from.AddDate(0, 1, 0)
+	default:
+		return from, to, fmt.Errorf("unknown period '%s', use today, week or month", period)
+	}
+
+	if fromDay != "" {
+		day, err := time.ParseInLocation("2006-01-02", fromDay, now.Location())
+		if err != nil {
+			return from, to, errors.New("--from must be YYYY-MM-DD")
+		}
+		from, to = day, today.AddDate(0, 0, 1)
+	}
+	if toDay != "" {
+		day, err := time.ParseInLocation("2006-01-02", toDay, now.Location())
+		if err != nil {
+			return from, to, errors.New("--to must be YYYY-MM-DD")
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+
+	if !to.After(from) {
+		return from, to, errors.New("--to is before --from")
+	}
+	return from, to, nil
+}
+
+// Sessions that started in [from, to)
+func SessionsInRange(data []JsonData, from time.Time, to time.Time) []Session {
+	sessions := []Session{}
+	for _, activity := range data {
+		for _, session := range activity.Sessions {
+			if !session.Start.Before(from) && session.Start.Before(to) {
+				sessions = append(sessions, session)
+			}
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Start.Before(sessions[j].Start)
+	})
+	return sessions
+}
+
+// Add up sessions by activity, project and task. A session counts on the day it started.
+func BuildReport(data []JsonData, from time.Time, to time.Time) Report {
+	report := Report{From: from, To: to}
+
+	for _, activity := range data {
+		item := ReportItem{Name: activity.Activity}
+
+		for _, session := range SessionsInRange([]JsonData{activity}, from, to) {
+			seconds := Seconds(app.Rounding.Counted(SessionDuration(session)))
+
+			project := session.Project
+			if project == "" {
+				project = noProject
+			}
+
+			item.Seconds += seconds
+			projectItem := AddToReportItem(&item.Items, project, seconds)
+			if session.Task != "" {
+				AddToReportItem(&projectItem.Items, session.Task, seconds)
+			}
+		}
+
+		if item.Seconds == 0 && len(item.Items) == 0 {
+			continue
+		}
+
+		report.Seconds += item.Seconds
+		report.Activities = append(report.Activities, item)
+	}
+
+	report.Minutes = RoundSeconds(report.Seconds)
+	RoundReportItems(report.Activities)
+	SortReportItems(report.Activities)
+	return report
+}
+
+// Add seconds to item with name, item is created if not exist
+func AddToReportItem(items *[]ReportItem, name string, seconds int) *ReportItem {
+	for i := range *items {
+		if (*items)[i].Name == name {
+			(*items)[i].Seconds += seconds
+			return &(*items)[i]
+		}
+	}
+
+	*items = append(*items, ReportItem{Name: name, Seconds: seconds})
+	return &(*items)[len(*items)-1]
+}
+
+// Minutes of every item from its seconds
+func RoundReportItems(items []ReportItem) {
+	for i := range items {
+		items[i].Minutes = RoundSeconds(items[i].Seconds)
+		RoundReportItems(items[i].Items)
+	}
+}
+
+// Minutes of a sum of seconds as they are shown
+func RoundSeconds(seconds int) int {
+	return app.Rounding.Round(time.Duration(seconds) * time.Second)
+}
+
+// Most time first on every level
+func SortReportItems(items []ReportItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Seconds > items[j].Seconds
+	})
+	for _, item := range items {
+		SortReportItems(item.Items)
+	}
+}
+
+func PrintReport(report Report) {
+	Feedback("\n<< Report ", report.From.Format("02.01.2006")+" - "+report.To.AddDate(0, 0, -1).Format("02.01.2006"), "", false)
+	Feedback(" (total ", FormatMinutes(report.Minutes), ") >>\n", false)
+
+	if len(report.Activities) == 0 {
+		Feedback("<< ", "No time saved in this period", " >>\n", true)
+		return
+	}
+
+	for _, activity := range report.Activities {
+		PrintReportItem(activity, report.Seconds, "")
+		for _, project := range activity.Items {
+			PrintReportItem(project, report.Seconds, "    ")
+			for _, task := range project.Items {
+				PrintReportItem(task, report.Seconds, "        ")
+			}
+		}
+	}
+}
+
+// Print name, time and percentage of the whole report
+func PrintReportItem(item ReportItem, total int, indent string) {
+	Feedback("<< "+indent+"[", item.Name, "] ", false)
+	Feedback("", FormatMinutes(item.Minutes), "", false)
+	Feedback(" (", Percent(item.Seconds, total), ") >>\n", false)
+}
+
+// Minutes as 1h 05m
+func FormatMinutes(total int) string {
+	sign := ""
+	if total < 0 {
+		sign = "-"
+		total = -total
+	}
+	hours, minutes := SplitMinutes(total)
+	return fmt.Sprintf("%s%dh %02dm", sign, hours, minutes)
+}
+
+func Percent(part int, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+}
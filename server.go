@@ -0,0 +1,736 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error answered with a http status
+type ApiError struct {
+	Status  int
+	Message string
+}
+
+func (e ApiError) Error() string {
+	return e.Message
+}
+
+func BadRequest(err error) error {
+	return ApiError{http.StatusBadRequest, err.Error()}
+}
+
+// tm serve [--addr 127.0.0.1:7777] [--token T]
+func CmdServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:7777", "address to listen on")
+	token := fs.String("token", os.Getenv("TM_TOKEN"), "bearer token clients must send (default $TM_TOKEN)")
+
+	positional, err := ParseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: tm " + subcommands["serve"].Usage)
+	}
+
+	Feedback("<< Serving API on ", "http://"+*addr+"/api/", "", false)
+	if *token != "" {
+		Feedback(" (", "bearer token required", ")", false)
+	}
+	Feedback("", "", " >>\n", false)
+
+	return http.ListenAndServe(*addr, NewApiHandler(*token))
+}
+
+// Handler for /api/ with optional bearer token
+func NewApiHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && !ValidToken(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong bearer token"})
+			return
+		}
+
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+		parts := strings.Split(path, "/")
+		result, err := RouteApi(r, parts)
+		if err != nil {
+			WriteApiError(w, err)
+			return
+		}
+
+		// Adding to a collection creates, timer actions only change the timer
+		status := http.StatusOK
+		if r.Method == http.MethodPost && parts[0] != "timer" {
+			status = http.StatusCreated
+		}
+		if result == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		WriteJson(w, status, result)
+	})
+	return mux
+}
+
+// Authorization header is "Bearer <token>", a bare token is refused
+func ValidToken(r *http.Request, token string) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	given := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// Find handler by method and path
+func RouteApi(r *http.Request, parts []string) (interface{}, error) {
+	route := r.Method + " " + ApiRoute(parts)
+
+	switch route {
+	case "GET activities":
+		return app.Store.Activities()
+	case "POST activities":
+		return ApiAddActivity(r)
+	case "GET activities/{id}":
+		return ApiActivity(parts[1])
+	case "PATCH activities/{id}":
+		return ApiRenameActivity(r, parts[1])
+	case "DELETE activities/{id}":
+		return nil, ApiDeleteActivity(r, parts[1])
+	case "POST activities/{id}/unarchive":
+		return nil, ApiUnarchiveActivity(parts[1])
+
+	case "GET activities/{id}/projects":
+		activity, err := ApiActivity(parts[1])
+		return activity.Projects, err
+	case "POST activities/{id}/projects":
+		return ApiAddProject(r, parts[1])
+	case "GET activities/{id}/projects/{id}":
+		return ApiProject(parts[1], parts[3])
+	case "PATCH activities/{id}/projects/{id}":
+		return ApiRenameProject(r, parts[1], parts[3])
+	case "DELETE activities/{id}/projects/{id}":
+		return nil, ApiDeleteProject(r, parts[1], parts[3])
+	case "POST activities/{id}/projects/{id}/unarchive":
+		return nil, ApiUnarchiveProject(parts[1], parts[3])
+
+	case "GET activities/{id}/projects/{id}/tasks":
+		return ApiTasks(r, parts[1], parts[3])
+	case "POST activities/{id}/projects/{id}/tasks":
+		return ApiAddTask(r, parts[1], parts[3])
+	case "GET activities/{id}/projects/{id}/tasks/{id}":
+		return ApiTask(parts[1], parts[3], parts[5])
+	case "PATCH activities/{id}/projects/{id}/tasks/{id}":
+		return ApiChangeTask(r, parts[1], parts[3], parts[5])
+	case "DELETE activities/{id}/projects/{id}/tasks/{id}":
+		return nil, ApiDeleteTask(parts[1], parts[3], parts[5])
+
+	case "GET sessions":
+		return ApiSessions(r)
+	case "POST sessions":
+		return ApiSaveSession(r, "")
+	case "GET sessions/{id}":
+		return ApiSession(parts[1])
+	case "PUT sessions/{id}":
+		return ApiSaveSession(r, parts[1])
+	case "DELETE sessions/{id}":
+		return nil, ApiDeleteSession(parts[1])
+
+	case "GET dashboard":
+		return ApiDashboard(r)
+
+	case "GET timer":
+		return ApiTimer()
+	case "POST timer/start":
+		return ApiStartTimer(r)
+	case "POST timer/pause":
+		return PauseTimer(app.Now())
+	case "POST timer/resume":
+		return ResumeTimer(app.Now())
+	case "POST timer/pomodoro":
+		return TogglePomodoro(app.Now())
+	case "POST timer/stop":
+		return ApiStopTimer(r)
+	}
+	return nil, ApiError{http.StatusNotFound, "no such endpoint: " + r.Method + " /api/" + strings.Join(parts, "/")}
+}
+
+// Path with ids replaced: activities/3/projects/0 -> activities/{id}/projects/{id}
+func ApiRoute(parts []string) string {
+	route := []string{}
+	for i, part := range parts {
+		if i%2 == 1 && parts[0] != "timer" {
+			part = "{id}"
+		}
+		route = append(route, part)
+	}
+	return strings.Join(route, "/")
+}
+
+/*<=================================================== Activities ===================================================>*/
+
+func ApiActivity(idText string) (JsonData, error) {
+	id, err := ApiId(idText)
+	if err != nil {
+		return JsonData{}, err
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return JsonData{}, err
+	}
+	index := FindIndexOf(id, data)
+	if index == -1 {
+		return JsonData{}, ErrNotFound
+	}
+	return data[index], nil
+}
+
+// Same checks as the add command on the commandline
+func ApiAddActivity(r *http.Request) (interface{}, error) {
+	var body struct {
+		Activity string `json:"activity"`
+		Short    string `json:"short"`
+	}
+	err := ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return nil, err
+	}
+	err = CheckActivityNames(body.Activity, body.Short, data)
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	return app.Store.AddActivity(ConvertAnswersToJsonData(body.Activity, body.Short))
+}
+
+// Rename activity, left out names stay. Same checks as adding.
+func ApiRenameActivity(r *http.Request, idText string) (interface{}, error) {
+	activity, err := ApiActivity(idText)
+	if err != nil {
+		return nil, err
+	}
+
+	body := struct {
+		Activity string `json:"activity"`
+		Short    string `json:"short"`
+	}{activity.Activity, activity.Short}
+	err = ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return nil, err
+	}
+	err = CheckActivityRenames(activity, body.Activity, body.Short, data)
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	err = RenameActivity(activity, body.Activity, body.Short)
+	if err != nil {
+		return nil, err
+	}
+	return ApiActivity(idText)
+}
+
+// Archive activity, ?purge=true deletes it with all its time
+func ApiDeleteActivity(r *http.Request, idText string) error {
+	activity, err := ApiActivity(idText)
+	if err != nil {
+		return err
+	}
+
+	running, err := TimerRunsFor(activity.Id)
+	if err != nil {
+		return err
+	}
+	if running {
+		return ApiError{http.StatusConflict, "'" + activity.Activity + "' is running, stop it first"}
+	}
+
+	if r.URL.Query().Get("purge") == "true" {
+		return app.Store.DeleteActivity(activity.Id)
+	}
+	return app.Store.ArchiveActivity(activity.Id, true)
+}
+
+func ApiUnarchiveActivity(idText string) error {
+	activity, err := ApiActivity(idText)
+	if err != nil {
+		return err
+	}
+	return app.Store.ArchiveActivity(activity.Id, false)
+}
+
+/*<=================================================== Projects ===================================================>*/
+
+// Project with id, it must belong to the activity in the path
+func ApiProject(activityText string, projectText string) (Project, error) {
+	activity, err := ApiActivity(activityText)
+	if err != nil {
+		return Project{}, err
+	}
+
+	id, err := ApiId(projectText)
+	if err != nil {
+		return Project{}, err
+	}
+	index := FindProjectIn(id, activity)
+	if index == -1 {
+		return Project{}, ErrNotFound
+	}
+	return activity.Projects[index], nil
+}
+
+// Same checks as the add command of projects on the commandline
+func ApiAddProject(r *http.Request, activityText string) (interface{}, error) {
+	activity, err := ApiActivity(activityText)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	err = ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return nil, err
+	}
+	err = CheckProjectName(body.Name, data)
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	return app.Store.AddProject(activity.Id, Project{Name: body.Name, Tasks: []Task{}})
+}
+
+// Rename project, same checks as adding. Its sessions get the new name too.
+func ApiRenameProject(r *http.Request, activityText string, projectText string) (interface{}, error) {
+	activity, err := ApiActivity(activityText)
+	if err != nil {
+		return nil, err
+	}
+	project, err := ApiProject(activityText, projectText)
+	if err != nil {
+		return nil, err
+	}
+
+	body := struct {
+		Name string `json:"name"`
+	}{project.Name}
+	err = ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return nil, err
+	}
+	err = CheckProjectRename(project, body.Name, data)
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	err = RenameProject(activity, project, body.Name)
+	if err != nil {
+		return nil, err
+	}
+	return ApiProject(activityText, projectText)
+}
+
+// Archive project, ?purge=true deletes it
+func ApiDeleteProject(r *http.Request, activityText string, projectText string) error {
+	project, err := ApiProject(activityText, projectText)
+	if err != nil {
+		return err
+	}
+
+	if r.URL.Query().Get("purge") == "true" {
+		return app.Store.DeleteProject(project.Id)
+	}
+	return app.Store.ArchiveProject(project.Id, true)
+}
+
+func ApiUnarchiveProject(activityText string, projectText string) error {
+	project, err := ApiProject(activityText, projectText)
+	if err != nil {
+		return err
+	}
+	return app.Store.ArchiveProject(project.Id, false)
+}
+
+/*<=================================================== Tasks ===================================================>*/
+
+// Task with id, it must belong to the project in the path
+func ApiTask(activityText string, projectText string, taskText string) (Task, error) {
+	project, err := ApiProject(activityText, projectText)
+	if err != nil {
+		return Task{}, err
+	}
+
+	id, err := ApiId(taskText)
+	if err != nil {
+		return Task{}, err
+	}
+	index := FindTaskIn(id, project)
+	if index == -1 {
+		return Task{}, ErrNotFound
+	}
+	return project.Tasks[index], nil
+}
+
+// Tasks of the project, ?status=open|todo|doing|done|overdue filters them (default all)
+func ApiTasks(r *http.Request, activityText string, projectText string) (interface{}, error) {
+	project, err := ApiProject(activityText, projectText)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := r.URL.Query().Get("status")
+	if filter == "" {
+		return project.Tasks, nil
+	}
+	tasks, err := FilterTasks(project.Tasks, filter, app.Now())
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+	return tasks, nil
+}
+
+func ApiAddTask(r *http.Request, activityText string, projectText string) (interface{}, error) {
+	project, err := ApiProject(activityText, projectText)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Task string `json:"task"`
+	}
+	err = ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+	if body.Task == "" {
+		return nil, BadRequest(errors.New("task can't be empty"))
+	}
+
+	return app.Store.AddTask(project.Id, NewTask(body.Task))
+}
+
+// Rename task or change its status, priority, due date (yyyy-mm-dd, "" clears) and notes.
+// Left out fields stay as they are.
+func ApiChangeTask(r *http.Request, activityText string, projectText string, taskText string) (interface{}, error) {
+	activity, err := ApiActivity(activityText)
+	if err != nil {
+		return nil, err
+	}
+	project, err := ApiProject(activityText, projectText)
+	if err != nil {
+		return nil, err
+	}
+	task, err := ApiTask(activityText, projectText, taskText)
+	if err != nil {
+		return nil, err
+	}
+
+	due := ""
+	if task.Due != nil {
+		due = task.Due.Format("2006-01-02")
+	}
+	body := struct {
+		Task     string `json:"task"`
+		Status   string `json:"status"`
+		Priority int    `json:"priority"`
+		Due      string `json:"due"`
+		Notes    string `json:"notes"`
+	}{task.Name, task.Status, task.Priority, due, task.Notes}
+	err = ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = CheckTaskRename(task, project, body.Task)
+	if err == nil {
+		err = CheckTaskStatus(body.Status)
+	}
+	if err == nil && (body.Priority < 0 || body.Priority >= len(priorityNames)) {
+		err = fmt.Errorf("priority must be 0 (none) - %d (high)", len(priorityNames)-1)
+	}
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	changed := WithStatus(task, body.Status, app.Now())
+	changed.Priority, changed.Notes = body.Priority, body.Notes
+	changed.Due, err = ParseDueDate(body.Due, app.Now())
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	if body.Status != task.Status || body.Priority != task.Priority || body.Due != due || body.Notes != task.Notes {
+		err = app.Store.UpdateTask(changed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = RenameTask(activity, project, task, body.Task)
+	if err != nil {
+		return nil, err
+	}
+	return ApiTask(activityText, projectText, taskText)
+}
+
+func ApiDeleteTask(activityText string, projectText string, taskText string) error {
+	task, err := ApiTask(activityText, projectText, taskText)
+	if err != nil {
+		return err
+	}
+	return app.Store.DeleteTask(task.Id)
+}
+
+/*<=================================================== Sessions ===================================================>*/
+
+// Sessions in ?from=YYYY-MM-DD&to=YYYY-MM-DD, optionally only ?activity=id
+func ApiSessions(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+
+	from, to := time.Time{}, app.Now().AddDate(100, 0, 0)
+	if query.Get("from") != "" || query.Get("to") != "" {
+		var err error
+		from, to, err = ReportRange("today", query.Get("from"), query.Get("to"), app.Now())
+		if err != nil {
+			return nil, BadRequest(err)
+		}
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return nil, err
+	}
+	if query.Get("activity") != "" {
+		activity, err := ApiActivity(query.Get("activity"))
+		if err != nil {
+			return nil, err
+		}
+		data = []JsonData{activity}
+	}
+
+	return SessionsInRange(data, from, to), nil
+}
+
+func ApiSession(idText string) (Session, error) {
+	id, err := ApiId(idText)
+	if err != nil {
+		return Session{}, err
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return Session{}, err
+	}
+	for _, activity := range data {
+		for _, session := range activity.Sessions {
+			if session.Id == id {
+				return session, nil
+			}
+		}
+	}
+	return Session{}, ErrNotFound
+}
+
+// Add new session or replace session with idText
+func ApiSaveSession(r *http.Request, idText string) (interface{}, error) {
+	var session Session
+	err := ReadJson(r, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	// Project and task are given by name, the names are checked and the store finds the ids
+	session.ProjectId, session.TaskId = nil, nil
+
+	// Changed session may overlap its old self
+	ignore := -1
+	if idText != "" {
+		session.Id, err = ApiId(idText)
+		if err != nil {
+			return nil, err
+		}
+		ignore = session.Id
+	}
+
+	data, err := app.Store.Activities()
+	if err != nil {
+		return nil, err
+	}
+	err = CheckSession(data, session, ignore)
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	if idText == "" {
+		return app.Store.AddSession(session)
+	}
+	return session, app.Store.UpdateSession(session)
+}
+
+// Session must belong to an activity, have a known project and task
+// and pass the same checks as logged time
+func CheckSession(data []JsonData, session Session, ignore int) error {
+	index := FindIndexOf(session.ActivityId, data)
+	if index == -1 {
+		return fmt.Errorf("no activity with id %d", session.ActivityId)
+	}
+
+	_, _, _, err := ResolveTimerTarget(data, fmt.Sprint(session.ActivityId), session.Project, session.Task)
+	if err != nil {
+		return err
+	}
+	return CheckLoggedSession(data, session, ignore)
+}
+
+func ApiDeleteSession(idText string) error {
+	id, err := ApiId(idText)
+	if err != nil {
+		return err
+	}
+	return app.Store.DeleteSession(id)
+}
+
+/*<=================================================== Timer ===================================================>*/
+
+// Running timer with elapsed time, {"running": false} if none
+func ApiTimer() (interface{}, error) {
+	// Breaks that passed are pause time
+	timer, _, err := AdvancePomodoro(app.Now())
+	if err != nil || timer == nil {
+		return map[string]interface{}{"running": false}, err
+	}
+
+	return map[string]interface{}{
+		"running": true,
+		"paused":  timer.PausedAt != nil,
+		"elapsed": int(TimerElapsed(*timer, app.Now()).Seconds()),
+		"timer":   timer,
+	}, nil
+}
+
+func ApiStartTimer(r *http.Request) (interface{}, error) {
+	var body struct {
+		Activity string `json:"activity"`
+		Project  string `json:"project"`
+		Task     string `json:"task"`
+		Pomodoro bool   `json:"pomodoro"`
+	}
+	err := ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	var timer Timer
+	err = WithTimerLock(func() error {
+		timer, err = BeginTimer(body.Activity, body.Project, body.Task, app.Now())
+		return err
+	})
+	var targetError TimerTargetError
+	if err == ErrTimerRunning {
+		return nil, ApiError{http.StatusConflict, fmt.Sprintf("'%s' is already running", timer.Activity)}
+	} else if errors.As(err, &targetError) {
+		return nil, BadRequest(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if body.Pomodoro {
+		return StartPomodoro(app.Now())
+	}
+	return timer, nil
+}
+
+// Stop timer, {"discard": true} throws the time away
+func ApiStopTimer(r *http.Request) (interface{}, error) {
+	var body struct {
+		Discard bool `json:"discard"`
+	}
+	err := ReadJson(r, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	var session *Session
+	err = WithTimerLock(func() error {
+		_, session, err = EndTimer(body.Discard, app.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"saved": session != nil, "session": session}, nil
+}
+
+/*<=================================================== Help functions ===================================================>*/
+
+func ApiId(text string) (int, error) {
+	id, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, BadRequest(fmt.Errorf("id '%s' must be a number", text))
+	}
+	return id, nil
+}
+
+// Decode request body, empty body is allowed
+func ReadJson(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil && err != io.EOF {
+		return BadRequest(fmt.Errorf("bad json: %w", err))
+	}
+	return nil
+}
+
+func WriteJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func WriteApiError(w http.ResponseWriter, err error) {
+	var apiError ApiError
+	switch {
+	case errors.As(err, &apiError):
+		WriteJson(w, apiError.Status, map[string]string{"error": apiError.Message})
+	case errors.Is(err, ErrNotFound):
+		WriteJson(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	case errors.Is(err, ErrNoTimer), errors.Is(err, ErrTimerPaused), errors.Is(err, ErrTimerNotPaused):
+		WriteJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrLocked):
+		WriteJson(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	default:
+		WriteJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
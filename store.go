@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Store keeps activities, projects, tasks and sessions.
+// Everything is addressed by its id, ids are never reused after delete.
+type Store interface {
+	// All activities with their projects, tasks and sessions
+	Activities() ([]JsonData, error)
+
+	// Add activity and return it with the new id
+	AddActivity(activity JsonData) (JsonData, error)
+
+	// Hide or show activity again, its sessions are kept
+	ArchiveActivity(id int, archived bool) error
+
+	// Change activity and short name
+	RenameActivity(id int, name string, short string) error
+
+	// Remove activity with its projects and sessions for good
+	DeleteActivity(id int) error
+
+	// Replace activity with the same id and everything in it, or put a deleted one back
+	// with its old ids. Only undo uses this, new things get new ids from the Add methods.
+	PutActivity(activity JsonData) error
+
+	// Add project with its tasks and return it with the new ids
+	AddProject(activityId int, project Project) (Project, error)
+	ArchiveProject(id int, archived bool) error
+
+	// Remove project with its tasks and sessions for good
+	DeleteProject(id int) error
+
+	// Rename project or task, sessions keep their ids and show the new name
+	RenameProject(id int, name string) error
+	RenameTask(id int, name string) error
+
+	// Add task and return it with the new id
+	AddTask(projectId int, task Task) (Task, error)
+
+	// Remove task, its sessions stay with the project
+	DeleteTask(id int) error
+
+	// Change status, priority, dates and notes of the task with the same id, the name stays
+	UpdateTask(task Task) error
+
+	// Add session and return it with the new id. Sessions without ProjectId and TaskId
+	// get them from the Project and Task names, here and in the two below.
+	AddSession(session Session) (Session, error)
+
+	// Add many sessions at once, all or nothing
+	AddSessions(sessions []Session) ([]Session, error)
+
+	// Add new activities with their projects and sessions, and new projects and sessions to
+	// the activities with the ids of additions, all or nothing. Returns the new activities.
+	Import(activities []JsonData, additions []JsonData) ([]JsonData, error)
+
+	// Replace session with the same id
+	UpdateSession(session Session) error
+	DeleteSession(id int) error
+
+	Close() error
+}
+
+var ErrNotFound = errors.New("not found")
+
+// Open store selected by TM_STORE environment variable (json or sqlite).
+// Changes go through the journal so they can be undone.
+func OpenStore() (Store, error) {
+	var store Store
+	var err error
+
+	switch os.Getenv("TM_STORE") {
+	case "sqlite":
+		store, err = OpenSqliteStore(sqliteFilename, filename)
+	case "", "json":
+		store, err = NewJsonStore(filename)
+	default:
+		return nil, errors.New("unknown TM_STORE '" + os.Getenv("TM_STORE") + "', use json or sqlite")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewJournalStore(store), nil
+}
+
+// Open sqlite store. A new database gets everything saved in the json file,
+// so switching to sqlite keeps the history. The json file is kept as it is.
+func OpenSqliteStore(filename string, jsonFilename string) (*SqliteStore, error) {
+	_, err := os.Stat(filename)
+	created := os.IsNotExist(err)
+
+	store, err := NewSqliteStore(filename)
+	if err != nil || !created {
+		return store, err
+	}
+
+	err = CopyJsonData(jsonFilename, store)
+	if err != nil {
+		// Next start tries again with a new database
+		store.Close()
+		os.Remove(filename)
+		return nil, fmt.Errorf("copy %s to %s: %w", jsonFilename, filename, err)
+	}
+	return store, nil
+}
+
+// Copy activities of the json file into the new database, nothing to do without the file
+func CopyJsonData(jsonFilename string, store *SqliteStore) error {
+	if _, err := os.Stat(jsonFilename); os.IsNotExist(err) {
+		return nil
+	}
+
+	jsonStore, err := NewJsonStore(jsonFilename)
+	if err != nil {
+		return err
+	}
+	data, err := jsonStore.load()
+	if err != nil || len(data.Activities) == 0 {
+		return err
+	}
+
+	err = store.ImportData(data)
+	if err != nil {
+		return err
+	}
+
+	Feedback("<< Copied ", fmt.Sprint(len(data.Activities), " activities"), " from "+jsonFilename+" to the sqlite database >>\n", false)
+	return nil
+}
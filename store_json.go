@@ -0,0 +1,507 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Store that keeps everything in one json file
+type JsonStore struct {
+	filename string
+}
+
+// Open json store, create the file if not exist and migrate it if it is old.
+// Both happen with the file locked, so two first starts don't both create it.
+func NewJsonStore(filename string) (s *JsonStore, err error) {
+	s = &JsonStore{filename: filename}
+
+	// Make new directory for the file, the lock is kept next to it
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := LockFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if unlockErr := lock.Unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return s, s.save(DataFile{Activities: []JsonData{}})
+	}
+	return s, s.migrate()
+}
+
+func (s *JsonStore) Activities() ([]JsonData, error) {
+	data, err := s.load()
+	return data.Activities, err
+}
+
+func (s *JsonStore) AddActivity(activity JsonData) (JsonData, error) {
+	err := s.update(func(data *DataFile) error {
+		var err error
+		activity, err = data.addActivity(activity)
+		return err
+	})
+	return activity, err
+}
+
+func (s *JsonStore) ArchiveActivity(id int, archived bool) error {
+	return s.update(func(data *DataFile) error {
+		index := FindIndexOf(id, data.Activities)
+		if index == -1 {
+			return ErrNotFound
+		}
+		data.Activities[index].Archived = archived
+		return nil
+	})
+}
+
+func (s *JsonStore) RenameActivity(id int, name string, short string) error {
+	return s.update(func(data *DataFile) error {
+		index := FindIndexOf(id, data.Activities)
+		if index == -1 {
+			return ErrNotFound
+		}
+		data.Activities[index].Activity = name
+		data.Activities[index].Short = short
+		return nil
+	})
+}
+
+func (s *JsonStore) DeleteActivity(id int) error {
+	return s.update(func(data *DataFile) error {
+		index := FindIndexOf(id, data.Activities)
+		if index == -1 {
+			return ErrNotFound
+		}
+		data.Activities = append(data.Activities[:index], data.Activities[index+1:]...)
+		return nil
+	})
+}
+
+func (s *JsonStore) PutActivity(activity JsonData) error {
+	return s.update(func(data *DataFile) error {
+		// Journal entries written before sessions kept ids have only the names
+		for i, session := range activity.Sessions {
+			session, err := withTargetIds(activity, session)
+			if err != nil {
+				return err
+			}
+			activity.Sessions[i] = session
+		}
+
+		index := FindIndexOf(activity.Id, data.Activities)
+		if index != -1 {
+			data.Activities[index] = activity
+			return nil
+		}
+
+		// Back to its place, activities are in the order they were added
+		at := len(data.Activities)
+		for i, value := range data.Activities {
+			if value.Id > activity.Id {
+				at = i
+				break
+			}
+		}
+		data.Activities = append(data.Activities[:at], append([]JsonData{activity}, data.Activities[at:]...)...)
+		data.RaiseNextIds()
+		return nil
+	})
+}
+
+func (s *JsonStore) AddProject(activityId int, project Project) (Project, error) {
+	err := s.update(func(data *DataFile) error {
+		index := FindIndexOf(activityId, data.Activities)
+		if index == -1 {
+			return ErrNotFound
+		}
+		project = data.newProject(project)
+		data.Activities[index].Projects = append(data.Activities[index].Projects, project)
+		return nil
+	})
+	return project, err
+}
+
+func (s *JsonStore) ArchiveProject(id int, archived bool) error {
+	return s.update(func(data *DataFile) error {
+		activityIndex, projectIndex, ok := FindProject(id, data.Activities)
+		if !ok {
+			return ErrNotFound
+		}
+		data.Activities[activityIndex].Projects[projectIndex].Archived = archived
+		return nil
+	})
+}
+
+func (s *JsonStore) DeleteProject(id int) error {
+	return s.update(func(data *DataFile) error {
+		activityIndex, projectIndex, ok := FindProject(id, data.Activities)
+		if !ok {
+			return ErrNotFound
+		}
+		activity := &data.Activities[activityIndex]
+		activity.Projects = append(activity.Projects[:projectIndex], activity.Projects[projectIndex+1:]...)
+
+		// Its time goes with it
+		sessions := []Session{}
+		for _, session := range activity.Sessions {
+			if session.ProjectId == nil || *session.ProjectId != id {
+				sessions = append(sessions, session)
+			}
+		}
+		activity.Sessions = sessions
+		return nil
+	})
+}
+
+func (s *JsonStore) RenameProject(id int, name string) error {
+	return s.update(func(data *DataFile) error {
+		activityIndex, projectIndex, ok := FindProject(id, data.Activities)
+		if !ok {
+			return ErrNotFound
+		}
+		data.Activities[activityIndex].Projects[projectIndex].Name = name
+		return nil
+	})
+}
+
+func (s *JsonStore) AddTask(projectId int, task Task) (Task, error) {
+	err := s.update(func(data *DataFile) error {
+		activityIndex, projectIndex, ok := FindProject(projectId, data.Activities)
+		if !ok {
+			return ErrNotFound
+		}
+		task.Id = data.NextIds.Task
+		data.NextIds.Task++
+
+		project := &data.Activities[activityIndex].Projects[projectIndex]
+		project.Tasks = append(project.Tasks, task)
+		return nil
+	})
+	return task, err
+}
+
+func (s *JsonStore) DeleteTask(id int) error {
+	return s.update(func(data *DataFile) error {
+		activityIndex, projectIndex, taskIndex, ok := FindTask(id, data.Activities)
+		if !ok {
+			return ErrNotFound
+		}
+		activity := &data.Activities[activityIndex]
+		project := &activity.Projects[projectIndex]
+		project.Tasks = append(project.Tasks[:taskIndex], project.Tasks[taskIndex+1:]...)
+
+		// Its time stays with the project
+		for index, session := range activity.Sessions {
+			if session.TaskId != nil && *session.TaskId == id {
+				activity.Sessions[index].TaskId = nil
+			}
+		}
+		return nil
+	})
+}
+
+func (s *JsonStore) UpdateTask(task Task) error {
+	return s.update(func(data *DataFile) error {
+		activityIndex, projectIndex, taskIndex, ok := FindTask(task.Id, data.Activities)
+		if !ok {
+			return ErrNotFound
+		}
+		project := &data.Activities[activityIndex].Projects[projectIndex]
+		task.Name = project.Tasks[taskIndex].Name
+		project.Tasks[taskIndex] = task
+		return nil
+	})
+}
+
+func (s *JsonStore) RenameTask(id int, name string) error {
+	return s.update(func(data *DataFile) error {
+		activityIndex, projectIndex, taskIndex, ok := FindTask(id, data.Activities)
+		if !ok {
+			return ErrNotFound
+		}
+		data.Activities[activityIndex].Projects[projectIndex].Tasks[taskIndex].Name = name
+		return nil
+	})
+}
+
+func (s *JsonStore) AddSession(session Session) (Session, error) {
+	err := s.update(func(data *DataFile) error {
+		index := FindIndexOf(session.ActivityId, data.Activities)
+		if index == -1 {
+			return ErrNotFound
+		}
+		var err error
+		session, err = withTargetIds(data.Activities[index], session)
+		if err != nil {
+			return err
+		}
+		session.Id = data.NextIds.Session
+		data.NextIds.Session++
+		data.Activities[index].Sessions = append(data.Activities[index].Sessions, session)
+		return nil
+	})
+	return session, err
+}
+
+func (s *JsonStore) AddSessions(sessions []Session) ([]Session, error) {
+	added := []Session{}
+	err := s.update(func(data *DataFile) error {
+		for _, session := range sessions {
+			index := FindIndexOf(session.ActivityId, data.Activities)
+			if index == -1 {
+				return ErrNotFound
+			}
+			session, err := withTargetIds(data.Activities[index], session)
+			if err != nil {
+				return err
+			}
+			session.Id = data.NextIds.Session
+			data.NextIds.Session++
+			data.Activities[index].Sessions = append(data.Activities[index].Sessions, session)
+			added = append(added, session)
+		}
+		return nil
+	})
+	return added, err
+}
+
+func (s *JsonStore) Import(activities []JsonData, additions []JsonData) ([]JsonData, error) {
+	added := []JsonData{}
+	err := s.update(func(data *DataFile) error {
+		for _, activity := range activities {
+			activity, err := data.addActivity(activity)
+			if err != nil {
+				return err
+			}
+			added = append(added, activity)
+		}
+
+		for _, addition := range additions {
+			index := FindIndexOf(addition.Id, data.Activities)
+			if index == -1 {
+				return ErrNotFound
+			}
+			activity := &data.Activities[index]
+
+			for _, project := range addition.Projects {
+				activity.Projects = append(activity.Projects, data.newProject(project))
+			}
+			for _, session := range addition.Sessions {
+				session, err := withTargetIds(*activity, session)
+				if err != nil {
+					return err
+				}
+				session.Id = data.NextIds.Session
+				session.ActivityId = activity.Id
+				data.NextIds.Session++
+				activity.Sessions = append(activity.Sessions, session)
+			}
+		}
+		return nil
+	})
+	return added, err
+}
+
+func (s *JsonStore) UpdateSession(session Session) error {
+	return s.update(func(data *DataFile) error {
+		index := FindIndexOf(session.ActivityId, data.Activities)
+		if index == -1 {
+			return ErrNotFound
+		}
+		session, err := withTargetIds(data.Activities[index], session)
+		if err != nil {
+			return err
+		}
+
+		if !removeSession(data.Activities, session.Id) {
+			return ErrNotFound
+		}
+
+		// Keep sessions in the order they were saved
+		sessions := append(data.Activities[index].Sessions, session)
+		sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Id < sessions[j].Id })
+		data.Activities[index].Sessions = sessions
+		return nil
+	})
+}
+
+func (s *JsonStore) DeleteSession(id int) error {
+	return s.update(func(data *DataFile) error {
+		if !removeSession(data.Activities, id) {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s *JsonStore) Close() error {
+	return nil
+}
+
+// Give activity with its projects and sessions new ids and add it
+func (d *DataFile) addActivity(activity JsonData) (JsonData, error) {
+	activity.Id = d.NextIds.Activity
+	d.NextIds.Activity++
+
+	for i, project := range activity.Projects {
+		activity.Projects[i] = d.newProject(project)
+	}
+	for i, session := range activity.Sessions {
+		session, err := withTargetIds(activity, session)
+		if err != nil {
+			return activity, err
+		}
+		activity.Sessions[i] = session
+		activity.Sessions[i].Id = d.NextIds.Session
+		activity.Sessions[i].ActivityId = activity.Id
+		d.NextIds.Session++
+	}
+
+	d.Activities = append(d.Activities, activity)
+	return activity, nil
+}
+
+// Session given by the names of its project and task gets their ids, ids that are set win
+func withTargetIds(activity JsonData, session Session) (Session, error) {
+	if session.ProjectId != nil || session.Project == "" {
+		return session, nil
+	}
+	var err error
+	session.ProjectId, session.TaskId, err = TargetIds(activity, session.Project, session.Task)
+	return session, err
+}
+
+// Give project and its tasks new ids
+func (d *DataFile) newProject(project Project) Project {
+	project.Id = d.NextIds.Project
+	d.NextIds.Project++
+
+	tasks := []Task{}
+	for _, task := range project.Tasks {
+		task.Id = d.NextIds.Task
+		d.NextIds.Task++
+		tasks = append(tasks, task)
+	}
+	project.Tasks = tasks
+	return project
+}
+
+// Remove session with id from whichever activity has it
+func removeSession(data []JsonData, id int) bool {
+	for i := range data {
+		for j, session := range data[i].Sessions {
+			if session.Id == id {
+				data[i].Sessions = append(data[i].Sessions[:j], data[i].Sessions[j+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Read file, change data and write it back.
+// The file stays locked for the whole cycle so other running instances wait for it.
+func (s *JsonStore) update(change func(data *DataFile) error) (err error) {
+	lock, err := LockFile(s.filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := lock.Unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	err = change(&data)
+	if err != nil {
+		return err
+	}
+
+	return s.save(data)
+}
+
+// Upgrade old data file to SchemaVersion, the old file is kept as backup.
+// The file must be locked.
+func (s *JsonStore) migrate() error {
+	file, err := ioutil.ReadFile(s.filename)
+	if err != nil {
+		return err
+	}
+
+	data, version, err := MigrateData(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.filename, err)
+	}
+	if version == SchemaVersion {
+		return nil
+	}
+
+	backup, err := BackupDataFile(s.filename, version)
+	if err != nil {
+		return err
+	}
+
+	err = s.save(data)
+	if err != nil {
+		return err
+	}
+
+	Feedback("<< Data file upgraded from version ", fmt.Sprint(version, " to ", SchemaVersion), "", false)
+	Feedback(", backup: ", backup, " >>\n", false)
+	return nil
+}
+
+// Open file and convert it to DataFile
+func (s *JsonStore) load() (DataFile, error) {
+	file, err := ioutil.ReadFile(s.filename)
+	if err != nil {
+		return DataFile{}, err
+	}
+
+	data, _, err := MigrateData(file)
+	if err != nil {
+		return DataFile{}, fmt.Errorf("%s: %w", s.filename, err)
+	}
+	return data, nil
+}
+
+// MarshalIndent data (makes json pretty) and replace the file
+func (s *JsonStore) save(data DataFile) error {
+	data.Version = SchemaVersion
+
+	// Sessions keep only the ids of their project and task, a copy is changed
+	activities := make([]JsonData, len(data.Activities))
+	for i, activity := range data.Activities {
+		sessions := make([]Session, len(activity.Sessions))
+		for j, session := range activity.Sessions {
+			session.Project, session.Task = "", ""
+			sessions[j] = session
+		}
+		activity.Sessions = sessions
+		activities[i] = activity
+	}
+	data.Activities = activities
+
+	dataBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.filename, dataBytes, 0644)
+}
@@ -0,0 +1,706 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+var sqliteFilename = "data/data.db"
+
+// sqliteMigrations[i] upgrades the database from user_version i to i+1.
+// Add new steps to the end, never change old ones.
+var sqliteMigrations = []string{`
+CREATE TABLE IF NOT EXISTS activities (
+	id       INTEGER PRIMARY KEY,
+	activity TEXT NOT NULL,
+	short    TEXT NOT NULL,
+	hours    INTEGER NOT NULL DEFAULT 0,
+	minutes  INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS projects (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	activity_id INTEGER NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+	name        TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS tasks (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	name       TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS sessions (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	activity_id INTEGER NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+	project     TEXT NOT NULL DEFAULT '',
+	task        TEXT NOT NULL DEFAULT '',
+	start       TEXT NOT NULL,
+	end         TEXT NOT NULL,
+	pause       INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS sessions_start ON sessions(start);
+`, `
+CREATE TABLE id_sequence (
+	name TEXT PRIMARY KEY,
+	last INTEGER NOT NULL
+);
+INSERT INTO id_sequence (name, last) SELECT 'activities', COALESCE(MAX(id), -1) FROM activities;
+`, `
+ALTER TABLE activities ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE projects ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+`, `
+ALTER TABLE sessions RENAME COLUMN pause TO pause_seconds;
+UPDATE sessions SET pause_seconds = pause_seconds * 60;
+`, `
+ALTER TABLE tasks ADD COLUMN status TEXT NOT NULL DEFAULT 'todo';
+ALTER TABLE tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN due TEXT;
+ALTER TABLE tasks ADD COLUMN created TEXT;
+ALTER TABLE tasks ADD COLUMN completed TEXT;
+ALTER TABLE tasks ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+`, `
+ALTER TABLE tasks ADD COLUMN pomodoros INTEGER NOT NULL DEFAULT 0;
+`, `
+ALTER TABLE sessions ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE;
+ALTER TABLE sessions ADD COLUMN task_id INTEGER REFERENCES tasks(id) ON DELETE SET NULL;
+UPDATE sessions SET project_id = (SELECT id FROM projects WHERE projects.activity_id = sessions.activity_id AND projects.name = sessions.project);
+UPDATE sessions SET task_id = (SELECT id FROM tasks WHERE tasks.project_id = sessions.project_id AND tasks.name = sessions.task);
+ALTER TABLE sessions DROP COLUMN project;
+ALTER TABLE sessions DROP COLUMN task;
+CREATE INDEX sessions_project ON sessions(project_id);
+CREATE INDEX sessions_task ON sessions(task_id);
+`,
+}
+
+// Store that keeps everything in an embedded sqlite database
+type SqliteStore struct {
+	db *sql.DB
+}
+
+// Open sqlite store and create the tables if not exist
+func NewSqliteStore(filename string) (*SqliteStore, error) {
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", filename+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SqliteStore{db: db}
+
+	err = s.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Upgrade database schema step by step, each step in its own transaction
+func (s *SqliteStore) migrate() error {
+	var version int
+	err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database version %d is newer than this program (version %d)", version, len(sqliteMigrations))
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		step := sqliteMigrations[version]
+		next := version + 1
+
+		err = s.transaction(func(tx *sql.Tx) error {
+			_, err := tx.Exec(step)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, next))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrate database version %d to %d: %w", version, next, err)
+		}
+	}
+	return nil
+}
+
+func (s *SqliteStore) Activities() ([]JsonData, error) {
+	data := []JsonData{}
+
+	rows, err := s.db.Query(`SELECT id, activity, short, hours, minutes, archived FROM activities ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Remember where each activity is in data
+	indexes := map[int]int{}
+
+	for rows.Next() {
+		activity := JsonData{Projects: []Project{}, Sessions: []Session{}}
+		err = rows.Scan(&activity.Id, &activity.Activity, &activity.Short, &activity.Hours, &activity.Minutes, &activity.Archived)
+		if err != nil {
+			return nil, err
+		}
+		indexes[activity.Id] = len(data)
+		data = append(data, activity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Add projects and their tasks
+	projectRows, err := s.db.Query(`
+		SELECT p.activity_id, p.id, p.name, p.archived,
+			t.id, t.name, t.status, t.priority, t.due, t.created, t.completed, t.notes, t.pomodoros
+		FROM projects p LEFT JOIN tasks t ON t.project_id = p.id
+		ORDER BY p.id, t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer projectRows.Close()
+
+	lastProject := -1
+	for projectRows.Next() {
+		var activityId, projectId int
+		var name string
+		var archived bool
+		var taskId, priority, pomodoros sql.NullInt64
+		var task, status, due, created, completed, notes sql.NullString
+
+		err = projectRows.Scan(&activityId, &projectId, &name, &archived,
+			&taskId, &task, &status, &priority, &due, &created, &completed, &notes, &pomodoros)
+		if err != nil {
+			return nil, err
+		}
+
+		activity := &data[indexes[activityId]]
+		if projectId != lastProject {
+			activity.Projects = append(activity.Projects, Project{Id: projectId, Name: name, Tasks: []Task{}, Archived: archived})
+			lastProject = projectId
+		}
+		if taskId.Valid {
+			t := Task{Id: int(taskId.Int64), Name: task.String, Status: status.String, Priority: int(priority.Int64),
+				Notes: notes.String, Pomodoros: int(pomodoros.Int64)}
+			t.Due, err = parseNullTime(due)
+			if err == nil {
+				t.Created, err = parseNullTime(created)
+			}
+			if err == nil {
+				t.Completed, err = parseNullTime(completed)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			project := &activity.Projects[len(activity.Projects)-1]
+			project.Tasks = append(project.Tasks, t)
+		}
+	}
+	if err = projectRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Add sessions
+	sessions, err := s.sessions()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		activity := &data[indexes[session.ActivityId]]
+		activity.Sessions = append(activity.Sessions, session)
+	}
+
+	return data, nil
+}
+
+func (s *SqliteStore) AddActivity(activity JsonData) (JsonData, error) {
+	err := s.transaction(func(tx *sql.Tx) error {
+		var err error
+		activity, err = insertActivity(tx, activity)
+		return err
+	})
+	return activity, err
+}
+
+func (s *SqliteStore) ArchiveActivity(id int, archived bool) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`UPDATE activities SET archived = ? WHERE id = ?`, archived, id))
+	})
+}
+
+func (s *SqliteStore) RenameActivity(id int, name string, short string) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`UPDATE activities SET activity = ?, short = ? WHERE id = ?`, name, short, id))
+	})
+}
+
+func (s *SqliteStore) DeleteActivity(id int) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`DELETE FROM activities WHERE id = ?`, id))
+	})
+}
+
+func (s *SqliteStore) PutActivity(activity JsonData) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return putActivity(tx, activity)
+	})
+}
+
+// Put everything of a json data file into the empty database, all ids stay.
+// Ids given out by the json store are not given again.
+func (s *SqliteStore) ImportData(data DataFile) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		for _, activity := range data.Activities {
+			err := putActivity(tx, activity)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(`UPDATE id_sequence SET last = MAX(last, ?) WHERE name = 'activities'`, data.NextIds.Activity-1)
+		if err != nil {
+			return err
+		}
+		for table, next := range map[string]int{"projects": data.NextIds.Project, "tasks": data.NextIds.Task, "sessions": data.NextIds.Session} {
+			_, err = tx.Exec(`DELETE FROM sqlite_sequence WHERE name = ?`, table)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT INTO sqlite_sequence (name, seq) VALUES (?, ?)`, table, next-1)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SqliteStore) AddProject(activityId int, project Project) (Project, error) {
+	err := s.transaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow(`SELECT id FROM activities WHERE id = ?`, activityId).Scan(&activityId)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		project, err = insertProject(tx, activityId, project)
+		return err
+	})
+	return project, err
+}
+
+func (s *SqliteStore) ArchiveProject(id int, archived bool) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`UPDATE projects SET archived = ? WHERE id = ?`, archived, id))
+	})
+}
+
+func (s *SqliteStore) DeleteProject(id int) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`DELETE FROM projects WHERE id = ?`, id))
+	})
+}
+
+func (s *SqliteStore) RenameProject(id int, name string) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`UPDATE projects SET name = ? WHERE id = ?`, name, id))
+	})
+}
+
+func (s *SqliteStore) AddTask(projectId int, task Task) (Task, error) {
+	err := s.transaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow(`SELECT id FROM projects WHERE id = ?`, projectId).Scan(&projectId)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		task.Id, err = insertTask(tx, projectId, task)
+		return err
+	})
+	return task, err
+}
+
+func (s *SqliteStore) DeleteTask(id int) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`DELETE FROM tasks WHERE id = ?`, id))
+	})
+}
+
+func (s *SqliteStore) UpdateTask(task Task) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`UPDATE tasks SET status = ?, priority = ?, due = ?, created = ?, completed = ?, notes = ?, pomodoros = ?
+			WHERE id = ?`, task.Status, task.Priority, nullTime(task.Due), nullTime(task.Created), nullTime(task.Completed),
+			task.Notes, task.Pomodoros, task.Id))
+	})
+}
+
+func (s *SqliteStore) RenameTask(id int, name string) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`UPDATE tasks SET name = ? WHERE id = ?`, name, id))
+	})
+}
+
+func (s *SqliteStore) AddSession(session Session) (Session, error) {
+	err := s.transaction(func(tx *sql.Tx) error {
+		var err error
+		session, err = insertSession(tx, session)
+		return err
+	})
+	return session, err
+}
+
+func (s *SqliteStore) AddSessions(sessions []Session) ([]Session, error) {
+	added := []Session{}
+	err := s.transaction(func(tx *sql.Tx) error {
+		for _, session := range sessions {
+			session, err := insertSession(tx, session)
+			if err != nil {
+				return err
+			}
+			added = append(added, session)
+		}
+		return nil
+	})
+	return added, err
+}
+
+func (s *SqliteStore) Import(activities []JsonData, additions []JsonData) ([]JsonData, error) {
+	added := []JsonData{}
+	err := s.transaction(func(tx *sql.Tx) error {
+		for _, activity := range activities {
+			activity, err := insertActivity(tx, activity)
+			if err != nil {
+				return err
+			}
+			added = append(added, activity)
+		}
+
+		for _, addition := range additions {
+			err := tx.QueryRow(`SELECT id FROM activities WHERE id = ?`, addition.Id).Scan(&addition.Id)
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			} else if err != nil {
+				return err
+			}
+
+			for _, project := range addition.Projects {
+				_, err = insertProject(tx, addition.Id, project)
+				if err != nil {
+					return err
+				}
+			}
+			for _, session := range addition.Sessions {
+				session.ActivityId = addition.Id
+				_, err = insertSession(tx, session)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return added, err
+}
+
+func (s *SqliteStore) UpdateSession(session Session) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		session, err := withTargetIdsOf(tx, session)
+		if err != nil {
+			return err
+		}
+		return mustChange(tx.Exec(`UPDATE sessions SET activity_id = ?, project_id = ?, task_id = ?, start = ?, end = ?, pause_seconds = ? WHERE id = ?`,
+			session.ActivityId, session.ProjectId, session.TaskId,
+			session.Start.Format(time.RFC3339Nano), session.End.Format(time.RFC3339Nano), session.PauseSeconds, session.Id))
+	})
+}
+
+func (s *SqliteStore) DeleteSession(id int) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		return mustChange(tx.Exec(`DELETE FROM sessions WHERE id = ?`, id))
+	})
+}
+
+func (s *SqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// All sessions in the order they were saved, with the names of their project and task
+func (s *SqliteStore) sessions() ([]Session, error) {
+	rows, err := s.db.Query(`
+		SELECT s.id, s.activity_id, s.project_id, s.task_id, COALESCE(p.name, ''), COALESCE(t.name, ''), s.start, s.end, s.pause_seconds
+		FROM sessions s LEFT JOIN projects p ON p.id = s.project_id LEFT JOIN tasks t ON t.id = s.task_id
+		ORDER BY s.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		var projectId, taskId sql.NullInt64
+		var start, end string
+
+		err = rows.Scan(&session.Id, &session.ActivityId, &projectId, &taskId, &session.Project, &session.Task,
+			&start, &end, &session.PauseSeconds)
+		if err != nil {
+			return nil, err
+		}
+		session.ProjectId = nullId(projectId)
+		session.TaskId = nullId(taskId)
+
+		session.Start, err = time.Parse(time.RFC3339Nano, start)
+		if err != nil {
+			return nil, err
+		}
+		session.End, err = time.Parse(time.RFC3339Nano, end)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Optional id, nil for NULL
+func nullId(id sql.NullInt64) *int {
+	if !id.Valid {
+		return nil
+	}
+	value := int(id.Int64)
+	return &value
+}
+
+// Optional time as text, NULL when not set
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Format(time.RFC3339Nano), Valid: true}
+}
+
+// Optional time from text, nil for NULL
+func parseNullTime(text sql.NullString) (*time.Time, error) {
+	if !text.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, text.String)
+	return &t, err
+}
+
+// Run changes in one transaction, roll back on error
+func (s *SqliteStore) transaction(change func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = change(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Insert activity with its projects and sessions, returns it with the new ids
+func insertActivity(tx *sql.Tx, activity JsonData) (JsonData, error) {
+	// Ids of deleted activities are not given again
+	_, err := tx.Exec(`UPDATE id_sequence SET last = last + 1 WHERE name = 'activities'`)
+	if err != nil {
+		return activity, err
+	}
+	err = tx.QueryRow(`SELECT last FROM id_sequence WHERE name = 'activities'`).Scan(&activity.Id)
+	if err != nil {
+		return activity, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO activities (id, activity, short, hours, minutes, archived) VALUES (?, ?, ?, ?, ?, ?)`,
+		activity.Id, activity.Activity, activity.Short, activity.Hours, activity.Minutes, activity.Archived)
+	if err != nil {
+		return activity, err
+	}
+
+	for i, project := range activity.Projects {
+		activity.Projects[i], err = insertProject(tx, activity.Id, project)
+		if err != nil {
+			return activity, err
+		}
+	}
+	for i, session := range activity.Sessions {
+		session.ActivityId = activity.Id
+		activity.Sessions[i], err = insertSession(tx, session)
+		if err != nil {
+			return activity, err
+		}
+	}
+	return activity, nil
+}
+
+// Insert project with its tasks, returns it with the new ids
+func insertProject(tx *sql.Tx, activityId int, project Project) (Project, error) {
+	result, err := tx.Exec(`INSERT INTO projects (activity_id, name, archived) VALUES (?, ?, ?)`, activityId, project.Name, project.Archived)
+	if err != nil {
+		return project, err
+	}
+
+	projectId, err := result.LastInsertId()
+	if err != nil {
+		return project, err
+	}
+	project.Id = int(projectId)
+
+	tasks := []Task{}
+	for _, task := range project.Tasks {
+		task.Id, err = insertTask(tx, project.Id, task)
+		if err != nil {
+			return project, err
+		}
+		tasks = append(tasks, task)
+	}
+	project.Tasks = tasks
+	return project, nil
+}
+
+func insertTask(tx *sql.Tx, projectId int, task Task) (int, error) {
+	result, err := tx.Exec(`INSERT INTO tasks (project_id, name, status, priority, due, created, completed, notes, pomodoros)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, projectId, task.Name, task.Status, task.Priority,
+		nullTime(task.Due), nullTime(task.Created), nullTime(task.Completed), task.Notes, task.Pomodoros)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// Insert session, returns it with the new id and the ids of its project and task
+func insertSession(tx *sql.Tx, session Session) (Session, error) {
+	session, err := withTargetIdsOf(tx, session)
+	if err != nil {
+		return session, err
+	}
+
+	result, err := tx.Exec(`INSERT INTO sessions (activity_id, project_id, task_id, start, end, pause_seconds) VALUES (?, ?, ?, ?, ?, ?)`,
+		session.ActivityId, session.ProjectId, session.TaskId,
+		session.Start.Format(time.RFC3339Nano), session.End.Format(time.RFC3339Nano), session.PauseSeconds)
+	if err != nil {
+		return session, err
+	}
+
+	id, err := result.LastInsertId()
+	session.Id = int(id)
+	return session, err
+}
+
+// Session given by the names of its project and task gets their ids, ids that are set win
+func withTargetIdsOf(tx *sql.Tx, session Session) (Session, error) {
+	if session.ProjectId != nil || session.Project == "" {
+		return session, nil
+	}
+
+	var projectId int
+	err := tx.QueryRow(`SELECT id FROM projects WHERE activity_id = ? AND name = ? ORDER BY id`,
+		session.ActivityId, session.Project).Scan(&projectId)
+	if err == sql.ErrNoRows {
+		return session, fmt.Errorf("no project '%s' in activity %d", session.Project, session.ActivityId)
+	} else if err != nil {
+		return session, err
+	}
+	session.ProjectId = &projectId
+
+	if session.Task == "" {
+		return session, nil
+	}
+	var taskId int
+	err = tx.QueryRow(`SELECT id FROM tasks WHERE project_id = ? AND name = ? ORDER BY id`, projectId, session.Task).Scan(&taskId)
+	if err == sql.ErrNoRows {
+		return session, fmt.Errorf("no task '%s' in project '%s'", session.Task, session.Project)
+	} else if err != nil {
+		return session, err
+	}
+	session.TaskId = &taskId
+	return session, nil
+}
+
+// Replace activity with the same id, or put it back with all its ids
+func putActivity(tx *sql.Tx, activity JsonData) error {
+	// Projects, tasks and sessions go with it
+	_, err := tx.Exec(`DELETE FROM activities WHERE id = ?`, activity.Id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO activities (id, activity, short, hours, minutes, archived) VALUES (?, ?, ?, ?, ?, ?)`,
+		activity.Id, activity.Activity, activity.Short, activity.Hours, activity.Minutes, activity.Archived)
+	if err != nil {
+		return err
+	}
+
+	// New activities never get the id
+	_, err = tx.Exec(`UPDATE id_sequence SET last = MAX(last, ?) WHERE name = 'activities'`, activity.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, project := range activity.Projects {
+		_, err = tx.Exec(`INSERT INTO projects (id, activity_id, name, archived) VALUES (?, ?, ?, ?)`,
+			project.Id, activity.Id, project.Name, project.Archived)
+		if err != nil {
+			return err
+		}
+		for _, task := range project.Tasks {
+			_, err = tx.Exec(`INSERT INTO tasks (id, project_id, name, status, priority, due, created, completed, notes, pomodoros)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, task.Id, project.Id, task.Name, task.Status, task.Priority,
+				nullTime(task.Due), nullTime(task.Created), nullTime(task.Completed), task.Notes, task.Pomodoros)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Journal entries written before sessions kept ids have only the names
+	for _, session := range activity.Sessions {
+		session.ActivityId = activity.Id
+		session, err := withTargetIdsOf(tx, session)
+		if err != nil {
+			return err
+		}
+		// Undo may move a session here from an activity that is put after this one
+		_, err = tx.Exec(`INSERT OR REPLACE INTO sessions (id, activity_id, project_id, task_id, start, end, pause_seconds) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			session.Id, activity.Id, session.ProjectId, session.TaskId,
+			session.Start.Format(time.RFC3339Nano), session.End.Format(time.RFC3339Nano), session.PauseSeconds)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return ErrNotFound if statement did not change any rows
+func mustChange(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// New sqlite database gets the json history with the same ids, ids given out stay taken
+func TestSqliteCopiesJsonData(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+	RunScript(t, clock, "c", "a", "api", "+30m", "q", "", "q")
+
+	store, err := OpenSqliteStore(sqliteFilename, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { store.Close() }()
+
+	data, err := store.Activities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || data[1].Activity != "writing" || data[1].Id != 1 || data[0].Projects[0].Name != "api" {
+		t.Fatalf("activities %+v", data)
+	}
+	AssertSession(t, data[0].Sessions[0], testStart, testStart.Add(30*time.Minute), 0, "")
+
+	added, err := store.AddActivity(JsonData{Activity: "reading", Short: "r"})
+	if err != nil || added.Id != 2 {
+		t.Fatalf("added %+v (%v), want id 2", added, err)
+	}
+	project, err := store.AddProject(added.Id, Project{Name: "books"})
+	if err != nil || project.Id != 1 {
+		t.Fatalf("project %+v (%v), want id 1", project, err)
+	}
+
+	// Opened again nothing is copied twice
+	store.Close()
+	store, err = OpenSqliteStore(sqliteFilename, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := store.Activities(); len(data) != 3 {
+		t.Fatalf("activities %+v", data)
+	}
+}
+
+// Both stores do the same: ids are never given twice, renames keep time, deletes take it along
+func TestStores(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(dir string) (Store, error)
+	}{
+		{"json", func(dir string) (Store, error) { return NewJsonStore(filepath.Join(dir, "data.json")) }},
+		{"sqlite", func(dir string) (Store, error) { return NewSqliteStore(filepath.Join(dir, "data.db")) }},
+	}
+
+	for _, test := range stores {
+		t.Run(test.name, func(t *testing.T) {
+			NewTestApp(t)
+			store, err := test.open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			must := func(err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			coding, err := store.AddActivity(JsonData{Activity: "coding", Short: "c"})
+			must(err)
+			api, err := store.AddProject(coding.Id, Project{Name: "api", Tasks: []Task{NewTask("docs")}})
+			must(err)
+			web, err := store.AddProject(coding.Id, Project{Name: "web"})
+			must(err)
+			task, err := store.AddTask(api.Id, NewTask("tests"))
+			must(err)
+			_, err = store.AddSessions([]Session{
+				{ActivityId: coding.Id, Project: "api", Task: "tests", Start: testStart, End: testStart.Add(time.Hour)},
+				{ActivityId: coding.Id, Project: "web", Start: testStart.Add(time.Hour), End: testStart.Add(2 * time.Hour)},
+			})
+			must(err)
+
+			// Unknown names save nothing
+			_, err = store.AddSessions([]Session{
+				{ActivityId: coding.Id, Start: testStart.Add(3 * time.Hour), End: testStart.Add(4 * time.Hour)},
+				{ActivityId: coding.Id, Project: "nope", Start: testStart.Add(4 * time.Hour), End: testStart.Add(5 * time.Hour)},
+			})
+			if err == nil {
+				t.Fatal("session of unknown project saved")
+			}
+
+			must(store.RenameProject(api.Id, "backend"))
+			must(store.RenameTask(task.Id, "unit tests"))
+			task.Status, task.Pomodoros = TaskDone, 3
+			must(store.UpdateTask(task))
+			must(store.ArchiveProject(web.Id, true))
+
+			data, err := store.Activities()
+			must(err)
+			activity := data[0]
+			if len(activity.Sessions) != 2 || activity.Projects[0].Tasks[1].Name != "unit tests" || activity.Projects[0].Tasks[1].Pomodoros != 3 || !activity.Projects[1].Archived {
+				t.Fatalf("activity %+v", activity)
+			}
+			AssertSession(t, activity.Sessions[0], testStart, testStart.Add(time.Hour), 0, "backend")
+			if activity.Sessions[0].Task != "unit tests" || ProjectDuration(activity, api.Id) != time.Hour {
+				t.Errorf("session %+v", activity.Sessions[0])
+			}
+
+			// Deleted task leaves its time to the project, deleted project takes it along
+			must(store.DeleteTask(task.Id))
+			must(store.DeleteProject(web.Id))
+			data, err = store.Activities()
+			must(err)
+			activity = data[0]
+			if len(activity.Sessions) != 1 || activity.Sessions[0].TaskId != nil || ProjectDuration(activity, api.Id) != time.Hour {
+				t.Fatalf("sessions %+v", activity.Sessions)
+			}
+
+			// Ids of deleted things are not given again
+			again, err := store.AddProject(coding.Id, Project{Name: "web"})
+			must(err)
+			data, err = store.Activities()
+			must(err)
+			if again.Id == web.Id || ProjectDuration(data[0], again.Id) != 0 {
+				t.Errorf("project %+v, deleted one had id %d", again, web.Id)
+			}
+			must(store.DeleteActivity(coding.Id))
+			writing, err := store.AddActivity(JsonData{Activity: "writing", Short: "w"})
+			must(err)
+			if writing.Id == coding.Id {
+				t.Errorf("activity got id %d again", writing.Id)
+			}
+			if err := store.DeleteSession(99); err != ErrNotFound {
+				t.Errorf("delete missing session: %v", err)
+			}
+		})
+	}
+}
+
+// Database of the version before ids are on sessions gets them from the names
+func TestSqliteMigratesSessionNames(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.db")
+	db, err := sql.Open("sqlite", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range sqliteMigrations[:6] {
+		if _, err := db.Exec(step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, statement := range []string{
+		`PRAGMA user_version = 6`,
+		`INSERT INTO activities (id, activity, short) VALUES (0, 'coding', 'c')`,
+		`INSERT INTO projects (activity_id, name) VALUES (0, 'api')`,
+		`INSERT INTO tasks (project_id, name) VALUES (1, 'docs')`,
+		`INSERT INTO sessions (activity_id, project, task, start, end) VALUES (0, 'api', 'docs', '2026-03-02T09:00:00Z', '2026-03-02T10:00:00Z')`,
+		`INSERT INTO sessions (activity_id, project, task, start, end) VALUES (0, 'gone', '', '2026-03-02T10:00:00Z', '2026-03-02T11:00:00Z')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	store, err := NewSqliteStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	data, err := store.Activities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := data[0].Sessions
+	if len(sessions) != 2 || sessions[0].ProjectId == nil || *sessions[0].ProjectId != 1 || sessions[0].Task != "docs" || sessions[1].ProjectId != nil {
+		t.Fatalf("sessions %+v", sessions)
+	}
+}
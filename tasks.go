@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tasks are todo when they are added, doing once the timer runs for them and done when finished.
+// Priority goes from 0 (none) to 3 (high), the due date is a day.
+
+const (
+	TaskTodo  = "todo"
+	TaskDoing = "doing"
+	TaskDone  = "done"
+)
+
+// Priority names by level
+var priorityNames = []string{"none", "low", "medium", "high"}
+
+// New task, todo and created now
+func NewTask(name string) Task {
+	created := app.Now()
+	return Task{Name: name, Status: TaskTodo, Created: &created}
+}
+
+// Name of priority level
+func PriorityName(priority int) string {
+	if priority < 0 || priority >= len(priorityNames) {
+		return fmt.Sprint(priority)
+	}
+	return priorityNames[priority]
+}
+
+// Parse none, low, medium or high, their first letter or 0 - 3
+func ParsePriority(answer string) (int, error) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	for level, name := range priorityNames {
+		if answer == name || answer == name[:1] || answer == strconv.Itoa(level) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority '%s', use none, low, medium or high", answer)
+}
+
+// Parse due date dd.mm.yyyy, yyyy-mm-dd, today or tomorrow. Empty or none clears it (nil).
+func ParseDueDate(answer string, now time.Time) (*time.Time, error) {
+	answer = strings.TrimSpace(answer)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch answer {
+	case "", "none":
+		return nil, nil
+	case "today":
+		return &today, nil
+	case "tomorrow":
+		tomorrow := today.AddDate(0, 0, 1)
+		return &tomorrow, nil
+	}
+
+	for _, layout := range []string{"02.01.2006", "2006-01-02"} {
+		due, err := time.ParseInLocation(layout, answer, now.Location())
+		if err == nil {
+			return &due, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a date, use dd.mm.yyyy, today, tomorrow or none", answer)
+}
+
+// Status must be todo, doing or done
+func CheckTaskStatus(status string) error {
+	if status != TaskTodo && status != TaskDoing && status != TaskDone {
+		return fmt.Errorf("unknown status '%s', use todo, doing or done", status)
+	}
+	return nil
+}
+
+// Task is not done and its due day is over
+func TaskOverdue(task Task, now time.Time) bool {
+	return task.Due != nil && task.Status != TaskDone && !now.Before(task.Due.AddDate(0, 0, 1))
+}
+
+// Tasks that pass filter: open (todo and doing, the default), all, todo, doing, done or overdue.
+// Done tasks come last, then higher priority and earlier due date first.
+func FilterTasks(tasks []Task, filter string, now time.Time) ([]Task, error) {
+	keep := map[string]func(task Task) bool{
+		"":        func(task Task) bool { return task.Status != TaskDone },
+		"open":    func(task Task) bool { return task.Status != TaskDone },
+		"all":     func(task Task) bool { return true },
+		TaskTodo:  func(task Task) bool { return task.Status == TaskTodo },
+		TaskDoing: func(task Task) bool { return task.Status == TaskDoing },
+		TaskDone:  func(task Task) bool { return task.Status == TaskDone },
+		"overdue": func(task Task) bool { return TaskOverdue(task, now) },
+	}[filter]
+	if keep == nil {
+		return nil, errors.New("unknown filter '" + filter + "', use open, all, todo, doing, done or overdue")
+	}
+
+	result := []Task{}
+	for _, task := range tasks {
+		if keep(task) {
+			result = append(result, task)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if (a.Status == TaskDone) != (b.Status == TaskDone) {
+			return b.Status == TaskDone
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Due != nil && b.Due != nil {
+			return a.Due.Before(*b.Due)
+		}
+		return a.Due != nil && b.Due == nil
+	})
+	return result, nil
+}
+
+// Change status of the task, done tasks get the time they were completed
+func SetTaskStatus(task Task, status string) error {
+	if task.Status == status {
+		return nil
+	}
+	return app.Store.UpdateTask(WithStatus(task, status, app.Now()))
+}
+
+// Task with new status, completed is set when it is done and cleared when it is not
+func WithStatus(task Task, status string, now time.Time) Task {
+	if task.Status == status {
+		return task
+	}
+
+	task.Status = status
+	task.Completed = nil
+	if status == TaskDone {
+		task.Completed = &now
+	}
+	return task
+}
+
+// Task the timer starts running for is doing, unless it is done already
+func MarkTaskDoing(task Task) error {
+	if task.Status != TaskTodo {
+		return nil
+	}
+	return SetTaskStatus(task, TaskDoing)
+}
+
+// Status, priority, due date, when it was done and pomodoros in one line
+func TaskDetails(task Task, now time.Time) string {
+	details := []string{task.Status}
+	if task.Priority > 0 {
+		details = append(details, PriorityName(task.Priority))
+	}
+	if task.Due != nil {
+		due := "due " + task.Due.Format("02.01.2006")
+		if TaskOverdue(task, now) {
+			due += " (overdue)"
+		}
+		details = append(details, due)
+	}
+	if task.Completed != nil {
+		details = append(details, "done "+task.Completed.Format("02.01.2006 15:04"))
+	}
+	if task.Pomodoros > 0 {
+		details = append(details, fmt.Sprint(task.Pomodoros, " pomodoros"))
+	}
+	return strings.Join(details, " | ")
+}
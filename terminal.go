@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/TwiN/go-color"
+	"golang.org/x/term"
+)
+
+// Where all output goes. Clearing and colors are skipped when output
+// is not a terminal, so redirected output stays clean.
+type Terminal struct {
+	out   io.Writer
+	tty   bool
+	color bool
+
+	// Pomodoro notices are printed while the commandline prints, one print at a time
+	mu sync.Mutex
+}
+
+// Colors are off for files, pipes and buffers, with NO_COLOR set and on TERM=dumb
+func NewTerminal(out io.Writer) *Terminal {
+	file, ok := out.(*os.File)
+	tty := ok && term.IsTerminal(int(file.Fd())) && os.Getenv("TERM") != "dumb"
+
+	// Old windows consoles without escape sequences are treated like a pipe
+	if tty {
+		tty = enableEscapes(file)
+	}
+
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &Terminal{out: out, tty: tty, color: tty && !noColor}
+}
+
+// Output is a terminal that understands escape sequences
+func (t *Terminal) IsTTY() bool {
+	return t.tty
+}
+
+// Clear screen and scrollback, move cursor to the top
+func (t *Terminal) Clear() {
+	if t.tty {
+		t.Print("\x1b[H\x1b[2J\x1b[3J")
+	}
+}
+
+func (t *Terminal) Print(items ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprint(t.out, items...)
+}
+
+// Write as it is, for output that is data and not messages
+func (t *Terminal) Write(data []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.out.Write(data)
+}
+
+// Ring the terminal bell, files and pipes get no bell character
+func (t *Terminal) Bell() {
+	if t.tty {
+		t.Print("\a")
+	}
+}
+
+// Item in color, plain text when colors are off
+func (t *Terminal) Colorize(style string, item interface{}) string {
+	if !t.color {
+		return fmt.Sprint(item)
+	}
+	return color.Colorize(style, fmt.Sprint(item))
+}
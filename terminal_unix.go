@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// Unix terminals understand escape sequences
+func enableEscapes(out *os.File) bool {
+	return true
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Running timer, saved to timerFilename whenever it changes
+// so it survives between invocations, crashes and reboots
+type Timer struct {
+	ActivityId int       `json:"activity_id"`
+	Activity   string    `json:"activity"`
+	ProjectId  *int      `json:"project_id,omitempty"`
+	TaskId     *int      `json:"task_id,omitempty"`
+	Start      time.Time `json:"start"`
+
+	// Names of the project and task, only for showing them
+	Project string `json:"project,omitempty"`
+	Task    string `json:"task,omitempty"`
+
+	// Pause in seconds, a pause going on started at PausedAt
+	PauseSeconds int        `json:"pause_seconds"`
+	PausedAt     *time.Time `json:"paused_at,omitempty"`
+
+	// Work and break intervals, nil when pomodoro mode is off
+	Pomodoro *Pomodoro `json:"pomodoro,omitempty"`
+}
+
+var timerFilename = "data/timer.json"
+
+var ErrTimerRunning = errors.New("timer is already running")
+var ErrNoTimer = errors.New("no timer running")
+var ErrTimerPaused = errors.New("timer is already paused")
+var ErrTimerNotPaused = errors.New("timer is not paused")
+
+// Timer can't start for the activity, project, task or start time that was asked for
+type TimerTargetError struct {
+	error
+}
+
+// Load running timer, nil if no timer is running
+func LoadTimer() (*Timer, error) {
+	file, err := ioutil.ReadFile(timerFilename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	timer := &Timer{}
+	err = json.Unmarshal(file, timer)
+	if err != nil {
+		return nil, err
+	}
+	return timer, nil
+}
+
+// Save running timer
+func SaveTimer(timer Timer) error {
+	dataBytes, err := json.MarshalIndent(timer, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(timerFilename, dataBytes, 0644)
+}
+
+// Load, change and save running timer. Does nothing if no timer is running.
+func UpdateTimer(change func(timer *Timer)) error {
+	return WithTimerLock(func() error {
+		timer, err := LoadTimer()
+		if err != nil || timer == nil {
+			return err
+		}
+
+		change(timer)
+		return SaveTimer(*timer)
+	})
+}
+
+// Remove running timer
+func RemoveTimer() error {
+	err := os.Remove(timerFilename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Run start/stop with the timer file locked so two invocations can't both start a timer
+func WithTimerLock(run func() error) (err error) {
+	lock, err := LockFile(timerFilename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := lock.Unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	return run()
+}
+
+// Timer runs for the activity, it can't be archived or deleted then
+func TimerRunsFor(activityId int) (bool, error) {
+	timer, err := LoadTimer()
+	if err != nil {
+		return false, err
+	}
+	return timer != nil && timer.ActivityId == activityId, nil
+}
+
+// Start timer for activity given by name, short name or id.
+// Returns the running timer and ErrTimerRunning if there is one.
+func BeginTimer(name string, project string, task string, start time.Time) (Timer, error) {
+	running, err := LoadTimer()
+	if err != nil {
+		return Timer{}, err
+	}
+	if running != nil {
+		return *running, ErrTimerRunning
+	}
+
+	data := OpenAndGetDataFromJson()
+	activity, project, task, err := ResolveTimerTarget(data, name, project, task)
+	if err != nil {
+		return Timer{}, TimerTargetError{err}
+	}
+
+	// Timer started earlier can't run over saved time
+	err = CheckOverlap(data, start, app.Now(), -1)
+	if err != nil {
+		return Timer{}, TimerTargetError{err}
+	}
+
+	timer := Timer{
+		ActivityId: activity.Id,
+		Activity:   activity.Activity,
+		Start:      start,
+	}
+	err = AimTimer(&timer, activity, project, task)
+	if err != nil {
+		return Timer{}, TimerTargetError{err}
+	}
+	return timer, SaveTimer(timer)
+}
+
+// Stop running timer and save it as a session unless discard is set.
+// Session is nil when the time was discarded or no time was worked.
+func EndTimer(discard bool, now time.Time) (Timer, *Session, error) {
+	timer, err := LoadTimer()
+	if err != nil {
+		return Timer{}, nil, err
+	}
+	if timer == nil {
+		return Timer{}, nil, ErrNoTimer
+	}
+
+	if discard {
+		return *timer, nil, RemoveTimer()
+	}
+
+	// Breaks that passed are pause time, work intervals are counted
+	_, err = catchUpPomodoro(timer, now)
+	if err != nil {
+		return *timer, nil, err
+	}
+
+	// Stopped right after start, an empty session is not saved
+	if TimerElapsed(*timer, now) <= 0 {
+		return *timer, nil, RemoveTimer()
+	}
+
+	RefreshTimerTarget(timer)
+	session, err := app.Store.AddSession(TimerToSession(*timer, now))
+	if err != nil {
+		return *timer, nil, err
+	}
+
+	// Remove timer only after the session is saved
+	return *timer, &session, RemoveTimer()
+}
+
+// Pause running timer
+func PauseTimer(now time.Time) (Timer, error) {
+	return changeTimer(func(timer *Timer) error {
+		if timer.PausedAt != nil {
+			return ErrTimerPaused
+		}
+		timer.PausedAt = &now
+		return nil
+	})
+}
+
+// Continue paused timer, the pause is added to pause time
+func ResumeTimer(now time.Time) (Timer, error) {
+	return changeTimer(func(timer *Timer) error {
+		if timer.PausedAt == nil {
+			return ErrTimerNotPaused
+		}
+		ResumePomodoro(timer, now)
+		timer.PauseSeconds = Seconds(TimerPause(*timer, now))
+		timer.PausedAt = nil
+		return nil
+	})
+}
+
+// Move running timer to another project and task. Time so far is saved as a session
+// of the old project and task, the timer goes on from now. Session is nil when nothing
+// was saved: the project and task stay the same or no time was worked yet.
+func SwitchTimerTarget(project string, task string, now time.Time) (Timer, *Session, error) {
+	var saved *Session
+	timer, err := changeTimer(func(timer *Timer) error {
+		if timer.Project == project && timer.Task == task {
+			return nil
+		}
+
+		// New project and task must be there before any time is saved
+		data := OpenAndGetDataFromJson()
+		index := FindIndexOf(timer.ActivityId, data)
+		if index == -1 {
+			return fmt.Errorf("activity '%s' is gone", timer.Activity)
+		}
+		projectId, taskId, err := TargetIds(data[index], project, task)
+		if err != nil {
+			return err
+		}
+
+		if TimerElapsed(*timer, now) > 0 {
+			RefreshTimerTarget(timer)
+			session, err := app.Store.AddSession(TimerToSession(*timer, now))
+			if err != nil {
+				return err
+			}
+			saved = &session
+
+			// A pause that is going on goes on
+			timer.Start = now
+			timer.PauseSeconds = 0
+			if timer.PausedAt != nil {
+				timer.PausedAt = &now
+			}
+		}
+
+		timer.ProjectId, timer.TaskId = projectId, taskId
+		timer.Project, timer.Task = project, task
+		return nil
+	})
+	return timer, saved, err
+}
+
+// Point timer at the project and task with these names in the activity, empty for none
+func AimTimer(timer *Timer, activity JsonData, project string, task string) error {
+	projectId, taskId, err := TargetIds(activity, project, task)
+	if err != nil {
+		return err
+	}
+	timer.ProjectId, timer.TaskId = projectId, taskId
+	timer.Project, timer.Task = project, task
+	return nil
+}
+
+// Project or task deleted while the timer ran is dropped, the time goes to the project
+// or the activity then. Names are read again from the ids.
+func RefreshTimerTarget(timer *Timer) {
+	data := OpenAndGetDataFromJson()
+	index := FindIndexOf(timer.ActivityId, data)
+	if index == -1 {
+		return
+	}
+
+	timer.Project, timer.Task = TargetNames(data[index], timer.ProjectId, timer.TaskId)
+	if timer.Project == "" {
+		timer.ProjectId = nil
+	}
+	if timer.Task == "" {
+		timer.TaskId = nil
+	}
+}
+
+// Load, change and save running timer, ErrNoTimer if no timer is running.
+// Pomodoro phases that passed come first.
+func changeTimer(change func(timer *Timer) error) (Timer, error) {
+	var changed Timer
+	err := WithTimerLock(func() error {
+		timer, err := LoadTimer()
+		if err != nil {
+			return err
+		}
+		if timer == nil {
+			return ErrNoTimer
+		}
+
+		_, err = catchUpPomodoro(timer, app.Now())
+		if err != nil {
+			return err
+		}
+
+		err = change(timer)
+		if err != nil {
+			return err
+		}
+
+		changed = *timer
+		return SaveTimer(changed)
+	})
+	return changed, err
+}
+
+// Pause time, a pause that is still going on counts until now
+func TimerPause(timer Timer, now time.Time) time.Duration {
+	pause := time.Duration(timer.PauseSeconds) * time.Second
+	if timer.PausedAt != nil && now.After(*timer.PausedAt) {
+		pause += now.Sub(*timer.PausedAt)
+	}
+	return pause
+}
+
+// Time worked since start, pause time removed
+func TimerElapsed(timer Timer, now time.Time) time.Duration {
+	return now.Sub(timer.Start) - TimerPause(timer, now)
+}
+
+// Save timer as a session that ends now
+func TimerToSession(timer Timer, now time.Time) Session {
+	return Session{
+		ActivityId:   timer.ActivityId,
+		ProjectId:    timer.ProjectId,
+		TaskId:       timer.TaskId,
+		Project:      timer.Project,
+		Task:         timer.Task,
+		Start:        timer.Start,
+		End:          now,
+		PauseSeconds: Seconds(TimerPause(timer, now)),
+	}
+}
+
+// Offer to resume, save or discard a timer left running by a crash or a closed terminal.
+// Returns the screen the commandline starts with.
+func RecoverTimer(state *CommandlineState) Screen {
+	reader := state.Reader
+
+	timer, err := LoadTimer()
+	ErrorHandling(err, "RecoverTimer")
+	if timer == nil {
+		return MainScreen
+	}
+
+	Feedback("\n<< Unfinished timer: [", timer.Activity, "]", true)
+	if timer.Project != "" {
+		Feedback(" Project: ", timer.Project, "", true)
+	}
+	Feedback(" started ", timer.Start.Format("02.01.2006 15:04:05"), "", true)
+	Feedback(" (", TimerElapsed(*timer, app.Now()).Round(time.Second), ") >>\n", true)
+
+	// Bookmark
+loop:
+
+	Feedback("<< | <", "resume", "> or ", false)
+	Feedback("<", "r", ">", false)
+	Feedback(" | <", "save", "> or ", false)
+	Feedback("<", "s", ">", false)
+	Feedback(" | <", "discard", "> or ", false)
+	Feedback("<", "d", "> | >>", false)
+	app.Out.Print(ColorGreen("\n=> "))
+
+	// Input ended, the timer is kept for the next start
+	answer, ok := Get_input(reader)
+	if !ok {
+		return QuitScreen
+	}
+
+	switch answer {
+	case "resume", "r":
+
+		// Find activity of the timer
+		data := OpenAndGetDataFromJson()
+		if FindIndexOf(timer.ActivityId, data) == -1 {
+			Feedback("<< [ERROR] Activity '", timer.Activity, "' not found! >>\n", true)
+			goto loop
+		}
+
+		// A pause that was going on ends now
+		now := app.Now()
+		err = UpdateTimer(func(t *Timer) {
+			t.PauseSeconds = Seconds(TimerPause(*t, now))
+			t.PausedAt = nil
+		})
+		ErrorHandling(err, "RecoverTimer")
+
+		// Continue the activity where it was left
+		state.Id = timer.ActivityId
+		state.Activity = timer.Activity
+		state.Start = timer.Start
+		state.Pause = TimerPause(*timer, now)
+		state.ProjectName = timer.Project
+		state.TaskName = timer.Task
+
+		ClearScreen()
+		return ActivityScreen
+
+	case "save", "s":
+
+		// Ask when the work ended
+		end, ok := AskForEndTime(reader, *timer)
+		if !ok {
+			return QuitScreen
+		}
+
+		// Save time to db
+		RefreshTimerTarget(timer)
+		_, err = app.Store.AddSession(TimerToSession(*timer, end))
+		ErrorHandling(err, "RecoverTimer")
+		if err != nil {
+			goto loop
+		}
+		ErrorHandling(RemoveTimer(), "RecoverTimer")
+
+		Feedback("<< ", "LAST TIME HAS BEEN SAVED", " >>\n", false)
+
+	case "discard", "d":
+		ErrorHandling(RemoveTimer(), "RecoverTimer")
+		Feedback("<< ", "LAST TIME NOT SAVED", " >>\n", true)
+
+	default:
+		goto loop
+	}
+	return MainScreen
+}
+
+// Ask end time as HH:MM, empty answer ends the timer when it was paused or now.
+// False when the input ended.
+func AskForEndTime(reader *bufio.Reader, timer Timer) (time.Time, bool) {
+	now := app.Now()
+
+	// Bookmark
+loop:
+
+	Feedback("\n<< End time? (", "HH:MM or dd.mm.yyyy HH:MM", ", empty for now) >>\n=> ", false)
+	answer, ok := Get_input(reader)
+	if !ok {
+		return now, false
+	}
+
+	if answer == "" {
+		if timer.PausedAt != nil {
+			return *timer.PausedAt, true
+		}
+		return now, true
+	}
+
+	end, err := ParseEndTime(answer, timer.Start)
+	if err != nil {
+		Feedback("[ERROR] : ", err.Error(), "\n", true)
+		goto loop
+	}
+	if end.After(now) {
+		Feedback("[ERROR] : ", "end time is in the future", "\n", true)
+		goto loop
+	}
+	return end, true
+}
+
+// Parse HH:MM on the day the timer started (or the day after if it is earlier than start)
+// or a full dd.mm.yyyy HH:MM
+func ParseEndTime(answer string, start time.Time) (time.Time, error) {
+	end, err := time.ParseInLocation("02.01.2006 15:04", answer, start.Location())
+	if err == nil {
+		if end.Before(start) {
+			return end, errors.New("end time is before start")
+		}
+		return end, nil
+	}
+
+	clock, err := time.ParseInLocation("15:04", answer, start.Location())
+	if err != nil {
+		return end, errors.New("use HH:MM or dd.mm.yyyy HH:MM")
+	}
+
+	end = time.Date(start.Year(), start.Month(), start.Day(), clock.Hour(), clock.Minute(), 0, 0, start.Location())
+	if end.Before(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end, nil
+}
@@ -2,18 +2,16 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"math"
 	"os"
-	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/TwiN/go-color"
-	"github.com/thedevsaddam/gojsonq"
+	"golang.org/x/term"
 )
 
 type JsonData struct {
@@ -23,31 +21,158 @@ type JsonData struct {
 	Hours    int       `json:"hours"`
 	Minutes  int       `json:"minutes"`
 	Projects []Project `json:"projects"`
+	Sessions []Session `json:"sessions"`
+
+	// Archived activities are hidden but their time still counts
+	Archived bool `json:"archived,omitempty"`
 }
 
+// Project of an activity, its id is unique in the whole data and never reused
 type Project struct {
-	Name  string   `json:"name"`
-	Tasks []string `json:"tasks"`
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Tasks    []Task `json:"tasks"`
+	Archived bool   `json:"archived,omitempty"`
+}
+
+// Task of a project, its id is unique in the whole data and never reused
+type Task struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+
+	// todo, doing or done, priority 0 (none) - 3 (high)
+	Status   string `json:"status"`
+	Priority int    `json:"priority"`
+
+	// Due day, when it was added and finished
+	Due       *time.Time `json:"due,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+	Completed *time.Time `json:"completed,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+
+	// Work intervals finished in pomodoro mode
+	Pomodoros int `json:"pomodoros,omitempty"`
+}
+
+// One saved run of an activity
+type Session struct {
+	Id         int `json:"id"`
+	ActivityId int `json:"activity_id"`
+	// Project and task the time was worked on, nil for none. Their names
+	// are looked up from the ids when sessions are read, only ids are saved.
+	ProjectId *int   `json:"project_id,omitempty"`
+	TaskId    *int   `json:"task_id,omitempty"`
+	Project   string `json:"project,omitempty"`
+	Task      string `json:"task,omitempty"`
+
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Pause in seconds, time is rounded only when it is shown
+	PauseSeconds int `json:"pause_seconds"`
 }
 
 var ProgramVersion = "1.3" // Update version
 var filename = "data/data.json"
 
+// Commandline commands, activities can't have these names
+var reservedWords = []string{"delete", "del", "quit", "q", "add", "a", "t", "top", "back", "b", "report", "unarchive", "undo", "redo", "edit", "rename", "log"}
+
 //go:generate goversioninfo -icon=resource/timem.ico -manifest=resource/goversioninfo.exe.manifest
 
 func main() {
 
-	// Create data.json file to save data if not exist
-	MakeDirAndJson()
+	// Open store, data.json file is created if not exist
+	var err error
+	app.Store, err = OpenStore()
+	if err != nil {
+		Feedback("OpenStore", ":", err.Error(), true)
+		os.Exit(1)
+	}
+	defer app.Store.Close()
+
+	// Rounding policy of reports and totals and pomodoro intervals, broken config falls back to the defaults
+	config, err := LoadConfig()
+	if err != nil {
+		Feedback("LoadConfig", ":", err.Error(), true)
+	}
+	app.Rounding = config.Rounding
+	app.Pomodoro = config.Pomodoro
+
+	// Run subcommand if given: tm start / stop / status / switch
+	if len(os.Args) > 1 {
+		code := RunSubcommand(os.Args[1:])
+		app.Store.Close()
+		os.Exit(code)
+	}
+
+	// Full screen ui in a terminal, TM_UI=classic keeps the line based commandline
+	if term.IsTerminal(int(os.Stdin.Fd())) && app.Out.IsTTY() && os.Getenv("TM_UI") != "classic" {
+		err = RunTui()
+		ErrorHandling(err, "RunTui")
+		return
+	}
 
-	// Start commandline
-	Commandline()
+	// Start commandline, it offers to resume a timer left running by a crash or closed terminal
+	RunCommandline()
 }
 
 /*<=================================================== Main functions ===================================================>*/
 
-// Command line
-func Commandline() {
+// Screens of the commandline
+type Screen int
+
+const (
+	MainScreen Screen = iota
+	ActivityScreen
+	ProjectScreen
+	TaskScreen
+	QuitScreen
+
+	// Stay on the screen and read the next command
+	SameScreen
+)
+
+// State shared by the screens of the commandline
+type CommandlineState struct {
+	Reader *bufio.Reader
+
+	// Running activity: id, name, start and time paused
+	Id       int
+	Activity string
+	Start    time.Time
+	Pause    time.Duration
+
+	// Timer starts in pomodoro mode
+	Pomodoro bool
+
+	// Selected project and task, the time is saved for them
+	ProjectId   int
+	ProjectName string
+	TaskName    string
+}
+
+// Every screen returns the screen that comes next
+var screens = map[Screen]func(state *CommandlineState) Screen{
+	MainScreen:     MainMenu,
+	ActivityScreen: StartActivity,
+	ProjectScreen:  ProjectsSwitch,
+	TaskScreen:     TasksSwitch,
+}
+
+func NewCommandlineState() *CommandlineState {
+	return &CommandlineState{Reader: app.In}
+}
+
+// Command line, runs screens one after another till quit
+func Commandline(state *CommandlineState, screen Screen) {
+	for screen != QuitScreen {
+		screen = screens[screen](state)
+	}
+	ClearScreen()
+}
+
+// Main menu with all activities
+func MainMenu(state *CommandlineState) Screen {
 
 	// Print commands to console
 	Commandline_commands()
@@ -55,175 +180,305 @@ func Commandline() {
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
-	// Get reader
-	reader := bufio.NewReader(os.Stdin)
-
 	for true {
 
-		command := Get_input(reader)
-		ActivitySwitch(command, data, reader)
+		// Input ended, stop the commandline
+		command, ok := Get_input(state.Reader)
+		if !ok {
+			return QuitScreen
+		}
+
+		next := ActivitySwitch(command, data, state)
+		if next != SameScreen {
+			return next
+		}
 
 	}
+	return MainScreen
 }
 
 // ActivitySwitch
-func ActivitySwitch(command string, data []JsonData, reader *bufio.Reader) {
+func ActivitySwitch(command string, data []JsonData, state *CommandlineState) Screen {
 
 	switch command {
 	case "top", "t":
-		topActivities()
+		return topActivities()
+	case "report":
+		return ReportActivities()
 	case "add", "a":
-		AddActivity()
+		return AddActivity()
 	case "delete", "del":
-		DeleteActivity()
+		return ArchiveActivity()
+	case "delete --purge", "del --purge":
+		return PurgeActivity()
+	case "unarchive":
+		return UnarchiveActivity()
+	case "edit", "rename":
+		return EditActivity()
+	case "log":
+		return LogActivity()
+	case "undo":
+		return ReplayOperations(true)
+	case "redo":
+		return ReplayOperations(false)
 	case "quit", "q", "00":
-		quit()
+		return QuitScreen
 	default:
 
-		start := time.Now()
+		// Activity name with --pomodoro starts in pomodoro mode
+		name := strings.TrimSuffix(command, " --pomodoro")
+		pomodoro := name != command
 
 		for _, value := range data {
-			if value.Activity == command || value.Short == command || fmt.Sprint(value.Id) == command {
+			if value.Activity == name || value.Short == name || fmt.Sprint(value.Id) == name {
+
+				// Archived activities can't be started
+				if value.Archived {
+					Feedback("<< [", value.Activity, "] is archived! (unarchive) >>", true)
+					app.Out.Print(ColorGreen("\n=> "))
+					return SameScreen
+				}
+
+				// Only one timer can run, it may have been started from another terminal
+				running, err := LoadTimer()
+				ErrorHandling(err, "ActivitySwitch")
+				if running != nil {
+					Feedback("<< [", running.Activity, "] is already running! (tm status) >>", true)
+					app.Out.Print(ColorGreen("\n=> "))
+					return SameScreen
+				}
+
+				// New run of the activity
+				*state = CommandlineState{Reader: state.Reader, Id: value.Id, Activity: value.Activity, Start: app.Now(), Pomodoro: pomodoro}
+
 				ClearScreen()
-				StartActivity(reader, start, value.Activity, value.Id)
+				return ActivityScreen
 			}
 		}
 
 		Feedback("<< ", "No such command or activity!", " >>", true)
-		fmt.Printf(ColorGreen("\n=> "))
+		app.Out.Print(ColorGreen("\n=> "))
 
 	}
+	return SameScreen
 }
 
 /*<=================================================== Activity functions ===================================================>*/
 
 // The loop
-func StartActivity(reader *bufio.Reader, start time.Time, Activity string, id int) {
+func StartActivity(state *CommandlineState) Screen {
 
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
-	// Prevent ERROR when some activity is deleted and max ID has been changed!
-	id = FindRealId(id)
+	// Find activity by id, it may have been deleted from another terminal
+	index := FindIndexOf(state.Id, data)
+	if index == -1 {
+		Feedback("<< [ERROR] Activity '", state.Activity, "' not found! >>\n", true)
+		return MainScreen
+	}
 
-	// Get hours and minutes from json
-	hours := data[id].Hours
-	minutes := data[id].Minutes
+	// Get hours and minutes from saved sessions
+	hours, minutes := SplitMinutes(ActivityMinutes(data[index]))
+
+	// Save running timer so it survives a crash or a closed terminal, a resumed timer keeps its pomodoro
+	timer := Timer{ActivityId: state.Id, Activity: state.Activity, Start: state.Start, PauseSeconds: Seconds(state.Pause)}
+	err := AimTimer(&timer, data[index], state.ProjectName, state.TaskName)
+	ErrorHandling(err, "StartActivity")
+	if err != nil {
+		state.ProjectName, state.TaskName = "", ""
+	}
+	if running, err := LoadTimer(); err == nil && running != nil && running.Start.Equal(state.Start) {
+		timer.Pomodoro = running.Pomodoro
+	}
+	if state.Pomodoro {
+		StartPomodoroOf(&timer, app.Now())
+		state.Pomodoro = false
+	}
+	err = SaveTimer(timer)
+	ErrorHandling(err, "StartActivity")
 
 	// Tell user about started activity
-	PrintActivityInfo(id, data, Activity, start, hours, minutes)
+	PrintActivityInfo(index, data, state.Activity, state.Start, hours, minutes)
 
 	// Print Projects
-	PrintProjects(id)
+	PrintProjects(state.Id)
 
-	// Start ProjectsSwitch
-	ProjectsSwitch(reader, start, id, Activity)
+	// Go to ProjectsSwitch
+	return ProjectScreen
 
 }
 
-func ProjectsSwitch(reader *bufio.Reader, start time.Time, id int, Activity string) {
-	// Loop for input
-	ProjectLoop := true
-
-	// Define Pause time
-	PauseTime := 0
+func ProjectsSwitch(state *CommandlineState) Screen {
+	id, Activity, start := state.Id, state.Activity, state.Start
 
-	for ProjectLoop {
+	for true {
 
 		PrintCommands("Projects")
 
-		// Get input from user
-		command := Get_input(reader)
+		// Get input from user, the timer is kept for the next start when the input ended
+		command, ok := Get_input(state.Reader)
+		if !ok {
+			return QuitScreen
+		}
+
+		// Pomodoro phases that passed while waiting
+		CheckPomodoro(state)
 
 		// Elapsed time since activity start
-		elapsed := time.Since(start)
+		elapsed := app.Now().Sub(start)
 
 		switch command {
 		case "add", "a":
 			// Add new project
 			AddProject(id)
 		case "del", "d", "delete":
-			// Delete project
-			DeleteProject(id)
+			// Archive project
+			ArchiveProject(id)
 			PrintElapsedTime(Activity, elapsed, start)
+		case "del --purge", "d --purge", "delete --purge":
+			// Delete project for good
+			PurgeProject(id)
+			PrintElapsedTime(Activity, elapsed, start)
+		case "unarchive", "u":
+			// Restore archived project
+			UnarchiveProject(id)
+		case "rename", "r":
+			// Rename project
+			EditProject(id)
 		case "projects", "p":
 			// Print all projects
 			ClearScreen()
 			PrintProjects(id)
 		case "select", "s":
 			// Select project
-			SelectProject(id, start, Activity, PauseTime)
+			next := SelectProject(state)
+			if next != SameScreen {
+				return next
+			}
 		case "quit", "00", "q":
 
-			SaveAndQuit(elapsed, reader, id, PauseTime)
-
-			// End loop
-			ProjectLoop = false
+			// Save and go back to main menu
+			return SaveAndQuit(elapsed, state)
 
 		case "pause", "+":
+			PauseActivity(state)
+		case "pomodoro", "pomo":
+			TogglePomodoroMode(state)
+		default:
+			ClearScreen()
+			PrintElapsedTime(Activity, elapsed, start)
 
-			// Tell user that this activity is paused
-			Feedback("<< [", Activity, "] paused! Press any key to continue! >>", true)
+		}
+	}
+	return ProjectScreen
+}
 
-			// Time now
-			startPause := time.Now()
+// Pause till enter is pressed. During a pomodoro break the timer is paused already, enter ends the break.
+func PauseActivity(state *CommandlineState) {
 
-			// Wait for pressing any key or enter
-			PressEnter()
-			ClearScreen()
+	// Tell user that this activity is paused
+	Feedback("<< [", state.Activity, "] paused! Press any key to continue! >>", true)
 
-			// Elapsed pause time
-			elapsedPause := time.Since(startPause)
+	// Time now
+	startPause := app.Now()
 
-			// Add minutes to pause time
-			PauseTime += int(math.Round(elapsedPause.Minutes()))
+	// Remember pause in saved timer
+	_, err := PauseTimer(startPause)
+	if !errors.Is(err, ErrTimerPaused) && !errors.Is(err, ErrNoTimer) {
+		ErrorHandling(err, "PauseActivity")
+	}
 
-			// Tell user about Unpause
-			Feedback("<< Unpaused [Pause time: ", elapsedPause, "] >>\n", false)
+	// Wait for pressing any key or enter
+	PressEnter()
+	ClearScreen()
 
-		default:
-			ClearScreen()
-			PrintElapsedTime(Activity, elapsed, start)
+	// Elapsed pause time
+	elapsedPause := app.Now().Sub(startPause)
 
-		}
+	// Save pause time to timer, a break may have ended already
+	_, err = ResumeTimer(app.Now())
+	if !errors.Is(err, ErrTimerNotPaused) && !errors.Is(err, ErrNoTimer) {
+		ErrorHandling(err, "PauseActivity")
+	}
+
+	// Add to pause time, kept to the second
+	if !SyncPause(state) {
+		state.Pause += elapsedPause
+	}
+
+	// Tell user about Unpause
+	Feedback("<< Unpaused [Pause time: ", elapsedPause, "] >>\n", false)
+}
+
+// Turn pomodoro mode of the running activity on or off
+func TogglePomodoroMode(state *CommandlineState) {
+	timer, err := TogglePomodoro(app.Now())
+	if err != nil {
+		ErrorHandling(err, "TogglePomodoroMode")
+		return
+	}
+	SyncPause(state)
+
+	if timer.Pomodoro == nil {
+		Feedback("\n<< ", "Pomodoro mode off", " >>\n", false)
+		return
+	}
+	Feedback("\n<< Pomodoro mode on: ", app.Pomodoro.Work, " minutes work, ", false)
+	Feedback("", app.Pomodoro.Break, " minutes break, ", false)
+	Feedback("", app.Pomodoro.LongBreak, " minutes long break every ", false)
+	Feedback("", app.Pomodoro.LongBreakEvery, " pomodoros >>\n", false)
+	Feedback("<< ", PomodoroStatus(*timer.Pomodoro), " >>\n", false)
+}
+
+// Catch up with pomodoro phases, ring the bell for them and take over the pause time of breaks
+func CheckPomodoro(state *CommandlineState) {
+	_, transitions, err := AdvancePomodoro(app.Now())
+	ErrorHandling(err, "CheckPomodoro")
+	AnnouncePomodoro(transitions)
+	SyncPause(state)
+}
+
+// Pause time of the running activity from its saved timer, false if the timer is gone
+func SyncPause(state *CommandlineState) bool {
+	timer, err := LoadTimer()
+	if err != nil || timer == nil || timer.ActivityId != state.Id || !timer.Start.Equal(state.Start) {
+		return false
 	}
+	state.Pause = TimerPause(*timer, app.Now())
+	return true
 }
 
-// Add new activity to json file
-func AddActivity() {
+// Add new activity to store
+func AddActivity() Screen {
 
 	// Questions array
 	questions := []string{"\n<< Activity name? >>", "\n << Short name? >>"}
 
 	// Get reader
-	reader := bufio.NewReader(os.Stdin)
+	reader := app.In
 
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
 	// Ask questions and check if they already exist in db
 	Answers := GetActivityAnswers(reader, questions, data)
-
-	// If db contains data then get a new id
-	GetId := CheckDBForData(data)
+	if Answers == nil {
+		return QuitScreen
+	}
 
 	// Convert to JsonData
-	NewValues := ConvertAnswersToJsonData(Answers[0], Answers[1], GetId)
+	NewValues := ConvertAnswersToJsonData(Answers[0], Answers[1])
 
-	// Add new Values to the end of file
-	data = append(data, NewValues)
-
-	// Convert it back to byte
-	dataBytes := MarshalIndentToByte(data, "AddItem")
-
-	// Override json file with updated data
-	WriteToFile(dataBytes)
+	// Add new Values to the end of store, store gives the id
+	_, err := app.Store.AddActivity(NewValues)
+	ErrorHandling(err, "AddItem")
 
 	// Clear the screen
 	ClearScreen()
 
 	// Go back to CommandLine
-	Commandline()
+	return MainScreen
 }
 
 func GetActivityAnswers(reader *bufio.Reader, questions []string, data []JsonData) []string {
@@ -238,22 +493,21 @@ func GetActivityAnswers(reader *bufio.Reader, questions []string, data []JsonDat
 
 		Feedback(value, "", "\n=> ", false)
 
-		// Get answer
-		readerAnswer := Get_input(reader)
+		// Get answer, nothing is added when the input ended
+		readerAnswer, ok := Get_input(reader)
+		if !ok {
+			return nil
+		}
 
 		// Check if name already exist in db
-		for _, value := range data {
-
-			switch readerAnswer {
-			case value.Activity, value.Short, "delete", "del", "quit", "q", "add", "a", "t", "top", "back", "b":
+		err := CheckActivityName(readerAnswer, data)
+		if err != nil {
 
-				// Tell user
-				Feedback("[ERROR] : '", readerAnswer, "' already exist in db\n", true)
-
-				// Restart the for loop, go to back to loop label
-				goto loop
-			}
+			// Tell user
+			Feedback("[ERROR] : ", err.Error(), "\n", true)
 
+			// Restart the for loop, go to back to loop label
+			goto loop
 		}
 
 		// Add answer to answer array
@@ -263,29 +517,59 @@ func GetActivityAnswers(reader *bufio.Reader, questions []string, data []JsonDat
 	return Answers
 }
 
-// Get Top activities
-func topActivities() {
+// Check activity and short name of a new activity, each one on its own. The same
+// name for both is fine. The commandline and the tui check every answer as it comes.
+func CheckActivityNames(name string, short string, data []JsonData) error {
+	for _, value := range []string{name, short} {
+		err := CheckActivityName(value, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Select file
-	jq := gojsonq.New().File(filename)
+// Check activity or short name: it can't be empty, a command or already in db
+func CheckActivityName(name string, data []JsonData) error {
+	if name == "" {
+		return errors.New("name can't be empty")
+	}
 
-	// Sort by activity, hours and minutes and select top 5
-	top := jq.Limit(5).SortBy("hours", "desc").Select("activity", "hours", "minutes").Get()
+	for _, value := range data {
+		if name == value.Activity || name == value.Short {
+			return fmt.Errorf("'%s' already exist in db", name)
+		}
+	}
 
-	toJson, _ := json.Marshal(top)
-	jsonString := string(toJson)
+	// Commands can't be used as names
+	if IsReservedWord(name) {
+		return fmt.Errorf("'%s' is a command", name)
+	}
+	return nil
+}
 
-	// Declared an empty map interface
-	var result []JsonData
+// Get Top activities
+func topActivities() Screen {
+
+	// Get data from json
+	result := OpenAndGetDataFromJson()
 
-	// Unmarshal or Decode the JSON to the interface.
-	json.Unmarshal([]byte(jsonString), &result)
+	// Sort by time spent, most first
+	sort.SliceStable(result, func(i, j int) bool {
+		return ActivityDuration(result[i]) > ActivityDuration(result[j])
+	})
+
+	// Select top 5
+	if len(result) > 5 {
+		result = result[:5]
+	}
 
 	for k, v := range result {
+		hours, minutes := SplitMinutes(ActivityMinutes(v))
 		Feedback("<< [", k+1, " Place]", false)
 		Feedback(" ", v.Activity, " ", false)
-		Feedback("(", v.Hours, " hours ", false)
-		Feedback("", v.Minutes, " minutes) >>\n", false)
+		Feedback("(", hours, " hours ", false)
+		Feedback("", minutes, " minutes) >>\n", false)
 	}
 
 	// Press enter to go back to commandline
@@ -296,18 +580,45 @@ func topActivities() {
 
 	ClearScreen()
 
-	// Start commandline
-	Commandline()
+	// Back to commandline
+	return MainScreen
+}
+
+// Print this week's report
+func ReportActivities() Screen {
+
+	// This week from monday
+	from, to, err := ReportRange("week", "", "", app.Now())
+	ErrorHandling(err, "ReportActivities")
+
+	PrintReport(BuildReport(OpenAndGetDataFromJson(), from, to))
+
+	// Press enter to go back to commandline
+	Feedback("\n<< PRESS", " ENTER ", "TO GO BACK TO COMMANDLINE >>", false)
+
+	// Check if enter is pressed
+	PressEnter()
+
+	ClearScreen()
+
+	// Back to commandline
+	return MainScreen
 }
 
-// Delete activity
-func DeleteActivity() {
+// Archive activity, it is hidden but its time still counts
+func ArchiveActivity() Screen {
 	// Ask for id
-	id := AskForId()
+	id, ok := AskForId()
 
 	// Clear the screen
 	ClearScreen()
 
+	// Back to commandline if cancelled
+	if !ok {
+		Feedback("<< ", "Exiting to commandline", " >>", true)
+		return MainScreen
+	}
+
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
@@ -315,23 +626,73 @@ func DeleteActivity() {
 	index := FindIndexOf(id, data)
 
 	// Check if index exist
-	if index == -1 {
+	if index == -1 || data[index].Archived {
 
 		// Tell user that index does not exist
 		Feedback("<< ID: '", id, "' not found! >>", true)
 
 		// Return to commandline
-		Commandline()
+		return MainScreen
 	}
 
-	// Delete
-	data = append(data[:index], data[index+1:]...)
+	// Running activity can't be archived
+	if CheckNotRunning(data[index]) {
+		return MainScreen
+	}
+
+	// Archive
+	err := app.Store.ArchiveActivity(id, true)
+	ErrorHandling(err, "ArchiveActivity")
+
+	// Tell about successful operation
+	Feedback("<< ID: '", id, "' archived! (unarchive to restore, del --purge to delete for good) >>", true)
 
-	// Convert it back to byte
-	dataBytes := MarshalIndentToByte(data, "DeleteItem")
+	// Press enter to continue
+	PressEnter()
 
-	// Override json file with updated data
-	WriteToFile(dataBytes)
+	// Return to commandline
+	return MainScreen
+}
+
+// Delete activity with its projects and time for good
+func PurgeActivity() Screen {
+	// Ask for id
+	id, ok := AskForId()
+
+	// Clear the screen
+	ClearScreen()
+
+	// Back to commandline if cancelled
+	if !ok {
+		Feedback("<< ", "Exiting to commandline", " >>", true)
+		return MainScreen
+	}
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Archived activities can be deleted too
+	index := FindIndexOf(id, data)
+	if index == -1 {
+		Feedback("<< ID: '", id, "' not found! >>", true)
+		return MainScreen
+	}
+
+	// Running activity can't be deleted
+	if CheckNotRunning(data[index]) {
+		return MainScreen
+	}
+
+	// Only typing yes deletes
+	if !PurgeCheckQuestion(data[index].Activity) {
+		ClearScreen()
+		Feedback("<< ", "Cancelled", " >>", true)
+		return MainScreen
+	}
+
+	// Delete
+	err := app.Store.DeleteActivity(id)
+	ErrorHandling(err, "DeleteItem")
 
 	// Tell about successful operation
 	Feedback("<< ID: '", id, "' Removed! >>", true)
@@ -340,20 +701,339 @@ func DeleteActivity() {
 	PressEnter()
 
 	// Return to commandline
-	Commandline()
+	return MainScreen
+}
+
+// Show archived activities and restore one of them
+func UnarchiveActivity() Screen {
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	ClearScreen()
+
+	// Print archived activities
+	archived := 0
+	for _, value := range data {
+		if value.Archived {
+			Feedback("<< [archived] ", value.Activity, " || ", false)
+			Feedback("", value.Short, "(", false)
+			Feedback("", value.Id, ") >>\n", false)
+			archived++
+		}
+	}
+	if archived == 0 {
+		Feedback("<< ", "No archived activities", " >>", true)
+		return MainScreen
+	}
+
+	// Ask for id
+	id, ok := AskForId()
+
+	// Clear the screen
+	ClearScreen()
+
+	// Back to commandline if cancelled
+	if !ok {
+		Feedback("<< ", "Exiting to commandline", " >>", true)
+		return MainScreen
+	}
+
+	// Only archived activities can be restored
+	index := FindIndexOf(id, data)
+	if index == -1 || !data[index].Archived {
+		Feedback("<< ID: '", id, "' is not archived! >>", true)
+		return MainScreen
+	}
+
+	// Restore
+	err := app.Store.ArchiveActivity(id, false)
+	ErrorHandling(err, "UnarchiveActivity")
+
+	// Tell about successful operation
+	Feedback("<< '", data[index].Activity, "' restored! >>", false)
+
+	// Return to commandline
+	return MainScreen
+}
+
+// Change name and short name of an activity
+func EditActivity() Screen {
+	// Ask for id
+	id, ok := AskForId()
+
+	// Clear the screen
+	ClearScreen()
+
+	// Back to commandline if cancelled
+	if !ok {
+		Feedback("<< ", "Exiting to commandline", " >>", true)
+		return MainScreen
+	}
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Find Index
+	index := FindIndexOf(id, data)
+	if index == -1 {
+		Feedback("<< ID: '", id, "' not found! >>", true)
+		return MainScreen
+	}
+	activity := data[index]
+
+	// Ask new names, same checks as adding
+	check := func(name string) error { return CheckActivityRename(activity, name, data) }
+	name := AskNewName("Activity name?", activity.Activity, check)
+	short := AskNewName("Short name?", activity.Short, check)
+
+	ClearScreen()
+
+	if name == activity.Activity && short == activity.Short {
+		Feedback("<< ", "Nothing changed", " >>", true)
+		return MainScreen
+	}
+
+	// Rename
+	err := RenameActivity(activity, name, short)
+	ErrorHandling(err, "EditActivity")
+
+	// Tell about successful operation
+	if err == nil {
+		Feedback("<< '", activity.Activity, "' renamed to '"+name+"' ("+short+")! >>", false)
+	}
+
+	// Return to commandline
+	return MainScreen
+}
+
+// Log time that was not taken with the timer
+func LogActivity() Screen {
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Bookmark
+activity:
+
+	Feedback("\n<< Activity? (", "name, short or id", ", q to cancel) >>\n=> ", false)
+	name, ok := Get_input(app.In)
+	if !ok {
+		return QuitScreen
+	}
+
+	// Back to commandline if cancelled
+	if name == "q" || name == "00" {
+		ClearScreen()
+		Feedback("<< ", "Exiting to commandline", " >>", true)
+		return MainScreen
+	}
+
+	// Archived activities can't get time
+	selected, _, _, err := ResolveTimerTarget(data, name, "", "")
+	if err != nil {
+		Feedback("[ERROR] : ", err.Error(), "\n", true)
+		goto activity
+	}
+
+	// Bookmark
+start:
+
+	Feedback("\n<< Start? (", "HH:MM, dd.mm.yyyy HH:MM or 20m ago", ") >>\n=> ", false)
+	answer, ok := Get_input(app.In)
+	if !ok {
+		return QuitScreen
+	}
+	start, err := ParseLogTime(answer, app.Now())
+	if err != nil {
+		Feedback("[ERROR] : ", err.Error(), "\n", true)
+		goto start
+	}
+
+	// Bookmark
+end:
+
+	Feedback("\n<< End or duration? (", "HH:MM or 1h30m", ", empty for now) >>\n=> ", false)
+	answer, ok = Get_input(app.In)
+	if !ok {
+		return QuitScreen
+	}
+
+	end := app.Now()
+	if duration, err := ParseLogDuration(answer); err == nil {
+		end = start.Add(duration)
+	} else if answer != "" {
+		end, err = ParseLogEnd(answer, start, app.Now())
+		if err != nil {
+			Feedback("[ERROR] : ", err.Error(), "\n", true)
+			goto end
+		}
+	}
+
+	// Project only when the activity has some
+	project := ""
+	if len(VisibleProjects(selected)) > 0 {
+		PrintProjects(selected.Id)
+
+	project:
+
+		Feedback("\n<< Project? (", "name or id", ", empty for none) >>\n=> ", false)
+		project, ok = Get_input(app.In)
+		if !ok {
+			return QuitScreen
+		}
+
+		_, project, _, err = ResolveTimerTarget(data, fmt.Sprint(selected.Id), project, "")
+		if err != nil {
+			Feedback("[ERROR] : ", err.Error(), "\n", true)
+			goto project
+		}
+	}
+
+	ClearScreen()
+
+	// Save, overlapping time is refused
+	session, err := LogSession(fmt.Sprint(selected.Id), project, "", start, end, 0)
+	if err != nil {
+		Feedback("<< [ERROR] ", err.Error(), " >>\n", true)
+	} else {
+		PrintLoggedSession(session)
+	}
+
+	// Press enter to continue
+	PressEnter()
+
+	// Return to commandline
+	return MainScreen
+}
+
+// Ask for a new name, empty answer keeps the current one
+func AskNewName(question string, current string, check func(name string) error) string {
+	// Bookmark
+loop:
+
+	Feedback("\n<< "+question+" (empty keeps '", current, "') >>\n=> ", false)
+
+	// Get answer, the name stays when the input ended
+	name, ok := Get_input(app.In)
+	if !ok || name == "" {
+		return current
+	}
+
+	// Check if name can be used
+	err := check(name)
+	if err != nil {
+
+		// Tell user
+		Feedback("[ERROR] : ", err.Error(), "\n", true)
+
+		// Ask again
+		goto loop
+	}
+	return name
+}
+
+// Tell user if the activity is running, it can't be archived or deleted then
+func CheckNotRunning(activity JsonData) bool {
+	running, err := TimerRunsFor(activity.Id)
+	ErrorHandling(err, "CheckNotRunning")
+	if running {
+		Feedback("<< [", activity.Activity, "] is running! (tm stop) >>", true)
+	}
+	return running
+}
+
+// Show the operations that can be undone (or redone), ask how many and do them
+func ReplayOperations(undo bool) Screen {
+	verb, doneWord := "redo", "Redone"
+	if undo {
+		verb, doneWord = "undo", "Undone"
+	}
+
+	// Get journal
+	journal, err := LoadJournal()
+	ErrorHandling(err, "ReplayOperations")
+
+	entries := journal.Redo
+	if undo {
+		entries = journal.Undo
+	}
+
+	ClearScreen()
+
+	// Last 10, newest first
+	pending := LastEntries(entries, 10)
+	if len(pending) == 0 {
+		Feedback("<< ", "Nothing to "+verb, " >>", true)
+		return MainScreen
+	}
+	PrintJournalEntries(pending)
+
+	// Bookmark
+loop:
+
+	Feedback("\n<< How many to ", verb, "? (empty for 1) >>\n=> ", false)
+	answer, ok := Get_input(app.In)
+	if !ok {
+		return QuitScreen
+	}
+
+	// Back to commandline if cancelled
+	if answer == "q" || answer == "00" {
+		ClearScreen()
+		Feedback("<< ", "Exiting to commandline", " >>", true)
+		return MainScreen
+	}
+
+	count := 1
+	if answer != "" {
+		count, err = strconv.Atoi(answer)
+		if err != nil || count < 1 || count > len(pending) {
+			Feedback("[ERROR] : ", fmt.Sprint("enter 1 - ", len(pending)), "\n", true)
+			goto loop
+		}
+	}
+
+	// Show what is going to happen before doing it
+	for _, entry := range pending[:count] {
+		Feedback("<< "+verb+": ", entry.Description, " >>\n", false)
+	}
+	if !AskYes(fmt.Sprint(verb, " ", count, " operation(s)?")) {
+		ClearScreen()
+		Feedback("<< ", "Cancelled", " >>", true)
+		return MainScreen
+	}
+
+	done, err := ReplayJournal(count, undo)
+	for _, entry := range done {
+		Feedback("<< "+doneWord+": ", entry.Description, " >>\n", false)
+	}
+	ErrorHandling(err, "ReplayOperations")
+
+	// Press enter to continue
+	PressEnter()
+
+	// Return to commandline
+	return MainScreen
 }
 
 // Save time
-func Save_time(reader *bufio.Reader, elapsed time.Duration, id int, PauseTime int) {
+func Save_time(elapsed time.Duration, state *CommandlineState) Screen {
 
 	// Print save message
 	Feedback("\n<< Do you want to save the time? (", "type no if not", ")\n=> ", false)
 
-	// Ask before delete
-	check := DeleteCheckQuestion("")
+	// Ask before delete, the timer is kept for the next start when the input ended
+	check, ok := DeleteCheckQuestion()
+	if !ok {
+		return QuitScreen
+	}
 
 	if check {
 
+		// Timer is not running anymore
+		ErrorHandling(RemoveTimer(), "Save_time")
+
 		// If 'no' is entered tell the user
 		Feedback("<< ", "LAST TIME NOT SAVED", " >>\n", true)
 
@@ -362,9 +1042,6 @@ func Save_time(reader *bufio.Reader, elapsed time.Duration, id int, PauseTime in
 
 		ClearScreen()
 
-		// Return to commandline
-		Commandline()
-
 	} else {
 
 		ClearScreen()
@@ -373,258 +1050,628 @@ func Save_time(reader *bufio.Reader, elapsed time.Duration, id int, PauseTime in
 		Feedback("<< ", "LAST TIME HAS BEEN SAVED", " >>\n", false)
 
 		// Save time to db
-		UpdateJsonFile(elapsed, id, PauseTime)
+		UpdateJsonFile(elapsed, state.Id, state.Pause, state.Start, state.ProjectName, state.TaskName)
 
-		// Return to commandline
-		Commandline()
+		// Timer is not running anymore
+		ErrorHandling(RemoveTimer(), "Save_time")
 	}
+
+	// Return to commandline
+	return MainScreen
 }
 
 // Save time function
-func UpdateJsonFile(elapsed time.Duration, id int, PauseTime int) {
+func UpdateJsonFile(elapsed time.Duration, id int, PauseTime time.Duration, start time.Time, ProjectName string, TaskName string) {
+	// Record this run as a new session
+	NewSession := Session{
+		ActivityId:   id,
+		Project:      ProjectName,
+		Task:         TaskName,
+		Start:        start,
+		End:          start.Add(elapsed),
+		PauseSeconds: Seconds(PauseTime),
+	}
+
+	// Project or task of the running timer deleted meanwhile, the time goes to what is left
+	if timer, err := LoadTimer(); err == nil && timer != nil && timer.ActivityId == id {
+		RefreshTimerTarget(timer)
+		NewSession.ProjectId, NewSession.TaskId = timer.ProjectId, timer.TaskId
+		NewSession.Project, NewSession.Task = timer.Project, timer.Task
+	}
+
+	// Add new session to db
+	_, err := app.Store.AddSession(NewSession)
+	ErrorHandling(err, "UpdateItem")
+}
+
+/*<=================================================== Project functions ===================================================>*/
+
+// Add new Project
+func AddProject(id int) {
+
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
-	//Old + new Minutes
-	NewMinutes := int(float64(data[id].Minutes) + math.Round(elapsed.Minutes()))
+	// Get project name and check if it already exist in db
+	pName, ok := GetAndCheckProject(data)
+	if !ok {
+		return
+	}
+
+	// Init new project
+	NewProject := Project{Name: pName, Tasks: []Task{}}
+
+	// Append new project to db, store gives the id
+	_, err := app.Store.AddProject(id, NewProject)
+	ErrorHandling(err, "UpdateItem")
+
+	// Tell the user about successful operation
+	Feedback("\n<< Project '", pName, "' added to db! >>\n", false)
+}
+
+// Ask project name till it can be used, false when the input ended
+func GetAndCheckProject(data []JsonData) (string, bool) {
+
+loop: // Bookmark
+
+	// Get reader
+	reader := app.In
+
+	// Ask for project name
+	Feedback("\n<< Project name? >>", "", "\n=> ", false)
 
-	// Remove PauseTime minutes
-	if PauseTime > 0 {
-		NewMinutes -= PauseTime
+	// Save answer, no project when the input ended
+	pName, ok := Get_input(reader)
+	if !ok {
+		return "", false
 	}
 
-	// Get hours out of all minutes
-	GetHours := NewMinutes / 60
+	// Check if project name already exist in db
+	err := CheckProjectName(pName, data)
+	if err != nil {
 
-	// Remove hours and get minutes left
-	GetMinutes := NewMinutes - (GetHours * 60)
+		// Tell user that project already exist in database
+		Feedback("\n<< [Error]: ", err.Error(), " >>\n", true)
 
-	// Add new hours
-	HoursToAdd := data[id].Hours + GetHours
+		// Restart the for loop, go to back to loop label
+		goto loop
+	}
+	return pName, true
+}
 
-	// Add new minutes
-	MinutesToAdd := GetMinutes
+// Check project name: it can't be empty or already in db
+func CheckProjectName(name string, data []JsonData) error {
+	if name == "" {
+		return errors.New("project name can't be empty")
+	}
 
-	// Add new minutes to db
-	data[id].Minutes = MinutesToAdd
+	for _, value := range data {
+		for _, v := range value.Projects {
+			if name == v.Name {
+				return fmt.Errorf("Project '%s' already exist in db", name)
+			}
+		}
+	}
+	return nil
+}
+
+// Check task name: it can't be empty or already in the project
+func CheckTaskName(name string, project Project) error {
+	if name == "" {
+		return errors.New("task name can't be empty")
+	}
+
+	for _, task := range project.Tasks {
+		if name == task.Name {
+			return fmt.Errorf("Task '%s' already exist in '%s'", name, project.Name)
+		}
+	}
+	return nil
+}
 
-	// Add new hours to db
-	data[id].Hours = HoursToAdd
+// Rename project of the activity
+func EditProject(id int) {
 
-	// Convert it back to byte
-	dataBytes := MarshalIndentToByte(data, "UpdateItem")
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Get project by id
+	project, ok := SelectProjectId(id, data)
 
-	// Override json file with updated data
-	WriteToFile(dataBytes)
+	// Back to projects if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return
+	}
+
+	// Ask new name, same checks as adding
+	name := AskNewName("Project name?", project.Name, func(name string) error {
+		return CheckProjectRename(project, name, data)
+	})
+	if name == project.Name {
+		Feedback("<< ", "Nothing changed", " >>\n", true)
+		return
+	}
+
+	// Rename
+	err := RenameProject(data[FindIndexOf(id, data)], project, name)
+	ErrorHandling(err, "EditProject")
+
+	// Tell user about successful operation
+	if err == nil {
+		Feedback("\n<< Project '", project.Name, "' renamed to '"+name+"'! >>\n", false)
+	}
 }
 
-/*<=================================================== Project functions ===================================================>*/
+// Archive project, it is hidden but its time still counts
+func ArchiveProject(id int) {
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Get project by id
+	project, ok := SelectProjectId(id, data)
+
+	// Back to projects if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return
+	}
+	if project.Archived {
+		Feedback("<< [ERROR] Project '", project.Name, "' is already archived! >>\n", true)
+		return
+	}
+
+	// Archive
+	err := app.Store.ArchiveProject(project.Id, true)
+	ErrorHandling(err, "ArchiveProject")
+
+	// Tell user about successful operation
+	Feedback("\nProject '", project.Name, "' archived! (unarchive to restore)\n", true)
+}
+
+// Delete project for good
+func PurgeProject(id int) {
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Get project by id, archived projects can be deleted too
+	project, ok := SelectProjectId(id, data)
+
+	// Back to projects if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return
+	}
+
+	// Only typing yes deletes
+	if !PurgeCheckQuestion(project.Name) {
+		ClearScreen()
+		return
+	}
+
+	// Delete
+	err := app.Store.DeleteProject(project.Id)
+	ErrorHandling(err, "DeleteItem")
+
+	// Tell user about successful operation
+	Feedback("\nProject '", project.Name, "' has been deleted!\n", true)
+}
+
+// Show archived projects of the activity and restore one of them
+func UnarchiveProject(id int) {
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Print archived projects
+	archived := 0
+	if index := FindIndexOf(id, data); index != -1 {
+		for _, value := range data[index].Projects {
+			if value.Archived {
+				Feedback("<< [archived] (", value.Id, ")'", false)
+				Feedback("", value.Name, "' >>\n", false)
+				archived++
+			}
+		}
+	}
+	if archived == 0 {
+		Feedback("<< ", "No archived projects", " >>\n", true)
+		return
+	}
+
+	// Get project by id
+	project, ok := SelectProjectId(id, data)
+
+	// Back to projects if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return
+	}
+	if !project.Archived {
+		Feedback("<< [ERROR] Project '", project.Name, "' is not archived! >>\n", true)
+		return
+	}
+
+	// Restore
+	err := app.Store.ArchiveProject(project.Id, false)
+	ErrorHandling(err, "UnarchiveProject")
+
+	// Tell user about successful operation
+	Feedback("\n<< Project '", project.Name, "' restored! >>\n", false)
+}
+
+/*<=================================================== Tasks functions ===================================================>*/
+// Print tasks of the project that pass filter: open (default), all, todo, doing, done or overdue
+func ShowTasks(projectId int, filter string) {
+
+	// Get data from json
+	data := OpenAndGetDataFromJson()
+
+	// Find project by id
+	activityIndex, projectIndex, ok := FindProject(projectId, data)
+	if !ok {
+		Feedback("\n<< [ERROR] Project ID: [", projectId, "] not found! >>\n", true)
+		return
+	}
+	project := data[activityIndex].Projects[projectIndex]
+
+	// Done tasks are hidden unless asked for
+	tasks, err := FilterTasks(project.Tasks, filter, app.Now())
+	if err != nil {
+		Feedback("\n<< [ERROR] ", err.Error(), " >>\n", true)
+		return
+	}
+
+	// Print tasks with id's, time spent on them, status, priority and due date
+	for _, task := range tasks {
+		Feedback("\nTask(", task.Id, ") : '", false)
+		Feedback("", task.Name, "' ", false)
+		Feedback("", FormatMinutes(app.Rounding.Round(TaskDuration(data[activityIndex], task.Id))), " | ", false)
+		Feedback("", TaskDetails(task, app.Now()), "", TaskOverdue(task, app.Now()))
+		if task.Notes != "" {
+			Feedback("\n    ", task.Notes, "", false)
+		}
+	}
+	app.Out.Print("\n")
+
+	// Tell about hidden tasks
+	if filter == "" {
+		if done, _ := FilterTasks(project.Tasks, TaskDone, app.Now()); len(done) > 0 {
+			Feedback("<< ", len(done), " done (show done) >>\n", false)
+		}
+	}
+}
+
+// Delete Task
+func DeleteTask(projectId int) {
+loop:
+	// Ask and save id
+	taskID, ok := AskForId()
 
-// Add new Project
-func AddProject(id int) {
+	// Back to tasks if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		PrintCommands("Tasks")
+		return
+	}
 
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
-	// Get project name and check if it already exist in db
-	pName := GetAndCheckProject(data)
+	// Task must belong to the selected project
+	taskIndex := -1
+	activityIndex, projectIndex, ok := FindProject(projectId, data)
+	if ok {
+		taskIndex = FindTaskIn(taskID, data[activityIndex].Projects[projectIndex])
+	}
+	if taskIndex == -1 {
+		Feedback("<< [ERROR] Task ID: [", taskID, "] not found! >>\n\n", true)
+		goto loop
+	}
 
-	// Init new project
-	NewProject := Project{pName, []string{}}
+	// Task details
+	task := data[activityIndex].Projects[projectIndex].Tasks[taskIndex]
 
-	// Append new project to db
-	data[id].Projects = append(data[id].Projects, NewProject)
+	// Ask before delete, only typing yes deletes
+	if AskYes("Do you really want to delete task '" + task.Name + "'?") {
+		// Delete
+		err := app.Store.DeleteTask(task.Id)
+		ErrorHandling(err, "DeleteItem")
 
-	// Convert it back to byte
-	dataBytes := MarshalIndentToByte(data, "UpdateItem")
+		// Tell user about successful operation
+		Feedback("\nTask '", task.Name, "' has been deleted!\n", true)
 
-	// Override json file with updated data
-	WriteToFile(dataBytes)
+		// Print commands
+		PrintCommands("Tasks")
 
-	// Tell the user about successful operation
-	Feedback("\n<< Project '", pName, "' added to db! >>\n", false)
+	} else {
+		// Print commands
+		PrintCommands("Tasks")
+	}
 }
 
-func GetAndCheckProject(data []JsonData) string {
+// Rename task of the project
+func EditTask(projectId int) {
+loop:
+	// Ask and save id
+	taskID, ok := AskForId()
 
-loop: // Bookmark
+	// Back to tasks if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		PrintCommands("Tasks")
+		return
+	}
 
-	// Get reader
-	reader := bufio.NewReader(os.Stdin)
+	// Get data from json
+	data := OpenAndGetDataFromJson()
 
-	// Ask for project name
-	Feedback("\n<< Project name? >>", "", "\n=> ", false)
+	// Task must belong to the selected project
+	taskIndex := -1
+	activityIndex, projectIndex, ok := FindProject(projectId, data)
+	if ok {
+		taskIndex = FindTaskIn(taskID, data[activityIndex].Projects[projectIndex])
+	}
+	if taskIndex == -1 {
+		Feedback("<< [ERROR] Task ID: [", taskID, "] not found! >>\n\n", true)
+		goto loop
+	}
 
-	// Save answer
-	pName := Get_input(reader)
+	// Task details
+	project := data[activityIndex].Projects[projectIndex]
+	task := project.Tasks[taskIndex]
 
-	// Check if project name already exist in db
-	for _, value := range data {
-		for _, v := range value.Projects {
-			switch pName {
-			case v.Name:
+	// Ask new name
+	name := AskNewName("Task name?", task.Name, func(name string) error {
+		return CheckTaskRename(task, project, name)
+	})
 
-				// Tell user that project already exist in database
-				Feedback("\n<< [Error]: Project '", pName, "' already exist in db >>\n", true)
+	if name != task.Name {
+		// Rename
+		err := RenameTask(data[activityIndex], project, task, name)
+		ErrorHandling(err, "EditTask")
 
-				// Restart the for loop, go to back to loop label
-				goto loop
-			}
+		// Tell user about successful operation
+		if err == nil {
+			Feedback("\nTask '", task.Name, "' renamed to '"+name+"'!\n", false)
 		}
-
 	}
-	return pName
+
+	// Print commands
+	PrintCommands("Tasks")
 }
 
-// Delete Project
-func DeleteProject(id int) {
+// Select project for the running activity
+func SelectProject(state *CommandlineState) Screen {
 
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
-	// Get project id
-	projectID := SelectProjectId(id, data)
+	// Get Project by id
+	project, ok := SelectProjectId(state.Id, data)
 
-	// Project details
-	project := data[id].Projects[projectID]
-
-	// Ask before delete
-	check := DeleteCheckQuestion(project.Name)
-
-	// If check is false delete item
-	if !check {
-		// Delete
-		data[id].Projects = append(data[id].Projects[:projectID], data[id].Projects[projectID+1:]...)
+	// Back to projects if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return SameScreen
+	}
 
-		// Convert it back to byte
-		dataBytes := MarshalIndentToByte(data, "DeleteItem")
+	// Archived projects can't be selected
+	if project.Archived {
+		Feedback("<< [ERROR] Project '", project.Name, "' is archived! (unarchive) >>\n", true)
+		return SameScreen
+	}
 
-		// Override json file with updated data
-		WriteToFile(dataBytes)
+	// Remember selected project, time so far is saved for the one before
+	state.ProjectId = project.Id
+	SwitchTarget(state, project.Name, "")
 
-		// Tell user about successful operation
-		Feedback("\nProject '", project.Name, "' has been deleted!\n", true)
-	} else {
-		ClearScreen()
-	}
+	return TaskScreen
 }
 
-/*<=================================================== Tasks functions ===================================================>*/
-// Print tasks
-func ShowTasks(id int, projectid int) {
+// Select task of the project for the running activity, selecting it again goes back to no task
+func SelectTask(state *CommandlineState) {
+	task, ok := AskForTask(state.ProjectId)
+	if !ok {
+		return
+	}
 
-	// Get data from json
-	data := OpenAndGetDataFromJson()
+	// Done tasks can't be selected
+	if task.Status == TaskDone {
+		Feedback("<< [ERROR] Task '", task.Name, "' is done! (reopen) >>\n", true)
+		return
+	}
 
-	// Save Project name and tasks
-	project := data[id].Projects[projectid]
+	name := task.Name
+	if name == state.TaskName {
+		name = ""
+	}
+	SwitchTarget(state, state.ProjectName, name)
 
-	// Print all tasks with id's
-	for key, value := range project.Tasks {
-		Feedback("\nTask(", key, ") : '", false)
-		Feedback("", value, "'", false)
+	// Tell user about selected task, it is being done now
+	if name != "" {
+		ErrorHandling(MarkTaskDoing(task), "SelectTask")
+		Feedback("\n<< Task: ", name, " >>\n", false)
+	} else {
+		Feedback("\n<< ", "No task", " >>\n", false)
 	}
-	fmt.Println()
 }
 
-// Delete Task
-func DeleteTask(id int, projectid int) {
+// Ask for id of a task of the project, false if cancelled
+func AskForTask(projectId int) (Task, bool) {
 loop:
 	// Ask and save id
-	taskID := AskForId()
+	taskID, ok := AskForId()
+
+	// Back to tasks if cancelled
+	if !ok {
+		Feedback("<< ", "Cancelled", " >>\n", true)
+		return Task{}, false
+	}
 
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
-	// Max task id
-	MaxTaskID := len(data[id].Projects[projectid].Tasks) - 1
-
-	if taskID > MaxTaskID {
-		Feedback("<< [ERROR] Max ID: [", MaxTaskID, "] >>\n\n", true)
+	// Task must belong to the project
+	taskIndex := -1
+	activityIndex, projectIndex, ok := FindProject(projectId, data)
+	if ok {
+		taskIndex = FindTaskIn(taskID, data[activityIndex].Projects[projectIndex])
+	}
+	if taskIndex == -1 {
+		Feedback("<< [ERROR] Task ID: [", taskID, "] not found! >>\n\n", true)
 		goto loop
-	} else if taskID < 0 {
+	}
+	return data[activityIndex].Projects[projectIndex].Tasks[taskIndex], true
+}
 
-		// ERROR message
-		Feedback("<< [ERROR] id cant be negative!", "", " >>\n\n", true)
-		goto loop
+// Mark task of the project done or reopen it
+func ChangeTaskStatus(projectId int, status string) {
+	task, ok := AskForTask(projectId)
+	if !ok {
+		return
 	}
 
-	// Project details
-	project := data[id].Projects[projectid]
+	err := SetTaskStatus(task, status)
+	ErrorHandling(err, "ChangeTaskStatus")
 
-	// Ask before delete
-	check := DeleteCheckQuestion(project.Tasks[taskID])
+	// Tell user about successful operation
+	if err == nil && status == TaskDone {
+		Feedback("\n<< Task '", task.Name, "' done! >>\n", false)
+	} else if err == nil {
+		Feedback("\n<< Task '", task.Name, "' reopened! >>\n", false)
+	}
+}
 
-	if !check {
-		// Delete
-		data[id].Projects[projectid].Tasks = append(data[id].Projects[projectid].Tasks[:taskID], data[id].Projects[projectid].Tasks[taskID+1:]...)
+// Change priority of a task of the project
+func ChangeTaskPriority(projectId int) {
+	task, ok := AskForTask(projectId)
+	if !ok {
+		return
+	}
 
-		// Convert it back to byte
-		dataBytes := MarshalIndentToByte(data, "DeleteItem")
+	// Ask new priority
+	answer := AskNewName("Priority? none, low, medium or high", PriorityName(task.Priority), func(answer string) error {
+		_, err := ParsePriority(answer)
+		return err
+	})
+	task.Priority, _ = ParsePriority(answer)
+
+	err := app.Store.UpdateTask(task)
+	ErrorHandling(err, "ChangeTaskPriority")
+	if err == nil {
+		Feedback("\n<< Task '", task.Name, "' priority: "+PriorityName(task.Priority)+" >>\n", false)
+	}
+}
 
-		// Override json file with updated data
-		WriteToFile(dataBytes)
+// Change due date of a task of the project
+func ChangeTaskDue(projectId int) {
+	task, ok := AskForTask(projectId)
+	if !ok {
+		return
+	}
 
-		// Tell user about successful operation
-		Feedback("\nTask '", project.Tasks[taskID], "' has been deleted!\n", true)
+	current := "none"
+	if task.Due != nil {
+		current = task.Due.Format("02.01.2006")
+	}
 
-		// Print commands
-		PrintCommands("Tasks")
+	// Ask new due date
+	answer := AskNewName("Due date? dd.mm.yyyy, today, tomorrow or none", current, func(answer string) error {
+		_, err := ParseDueDate(answer, app.Now())
+		return err
+	})
+	task.Due, _ = ParseDueDate(answer, app.Now())
+
+	err := app.Store.UpdateTask(task)
+	ErrorHandling(err, "ChangeTaskDue")
+	if err == nil {
+		Feedback("\n<< Task '", task.Name, "' due: "+answer+" >>\n", false)
+	}
+}
 
-	} else {
-		// Print commands
-		PrintCommands("Tasks")
+// Change notes of a task of the project, - clears them
+func ChangeTaskNotes(projectId int) {
+	task, ok := AskForTask(projectId)
+	if !ok {
+		return
+	}
+
+	// Ask new notes
+	notes := AskNewName("Notes? - clears them", task.Notes, func(string) error { return nil })
+	if notes == "-" {
+		notes = ""
+	}
+	task.Notes = notes
+
+	err := app.Store.UpdateTask(task)
+	ErrorHandling(err, "ChangeTaskNotes")
+	if err == nil {
+		Feedback("\n<< Task '", task.Name, "' notes saved! >>\n", false)
 	}
 }
 
-// Add task to project
-func SelectProject(id int, start time.Time, Activity string, PauseTime int) {
+// Change project and task of the running activity.
+// Time so far is saved for the ones before and the activity goes on from now.
+func SwitchTarget(state *CommandlineState, project string, task string) {
+	timer, session, err := SwitchTimerTarget(project, task, app.Now())
+	if err != nil && !errors.Is(err, ErrNoTimer) {
+		ErrorHandling(err, "SwitchTarget")
+		return
+	}
 
-	// Get data from json
-	data := OpenAndGetDataFromJson()
+	// Saved session ends where the new one starts
+	if session != nil {
+		Feedback("\n<< Saved ", FormatMinutes(SessionMinutes(*session)), " for '", false)
+		Feedback("", strings.Join(NonEmpty(state.Activity, session.Project, session.Task), " / "), "' >>\n", false)
+		state.Start = timer.Start
+		state.Pause = 0
+	}
 
-	// Get Project id
-	ProjectId := SelectProjectId(id, data)
+	state.ProjectName = project
+	state.TaskName = task
+}
 
-	// Get reader
-	reader := bufio.NewReader(os.Stdin)
+func TasksSwitch(state *CommandlineState) Screen {
+	id, Activity := state.Id, state.Activity
+	ProjectName, ProjectId := state.ProjectName, state.ProjectId
 
 	ClearScreen()
 
-	// Get Project Name by activity id and project id
-	ProjectName := GetProjectName(id, ProjectId, data)
-
 	// Print project name
 	Feedback("\n<< Project: ", ProjectName, " >>\n", false)
 
-	// Show tasks
-	ShowTasks(id, ProjectId)
+	// Show open tasks
+	ShowTasks(ProjectId, "")
 
 	// Print add task commands
 	PrintCommands("Tasks")
 
-	// Start TasksSwitch commands loop
-	TasksSwitch(reader, start, Activity, id, ProjectName, ProjectId, PauseTime)
-}
-
-func TasksSwitch(reader *bufio.Reader, start time.Time, Activity string, id int, ProjectName string, ProjectId int, PauseTime int) {
-
-	Tasksloop := true
+	for true {
 
-	for Tasksloop {
+		// Get input from user, show takes a filter: show done, s all ...
+		input, ok := Get_input(state.Reader)
+		if !ok {
+			return QuitScreen
+		}
+		command, filter, _ := strings.Cut(input, " ")
 
-		// Get input from user
-		command := Get_input(reader)
+		// Pomodoro phases that passed while waiting
+		CheckPomodoro(state)
 
-		// Elapsed time since activity start
-		elapsed := time.Since(start)
+		// Elapsed time since activity start, start moves when the task changes
+		start := state.Start
+		elapsed := app.Now().Sub(start)
 
 		switch command {
 
 		case "back", "b":
 
-			// End loop
-			Tasksloop = false
+			// Project is not selected anymore, time so far is saved for it
+			SwitchTarget(state, "", "")
+			start, elapsed = state.Start, app.Now().Sub(state.Start)
 
 			ClearScreen()
 
@@ -633,18 +1680,44 @@ func TasksSwitch(reader *bufio.Reader, start time.Time, Activity string, id int,
 
 			// Print Projects
 			PrintProjects(id)
+
+			// Back to projects
+			return ProjectScreen
+
 		case "add", "a":
-			AddTask(ProjectName, ProjectId, id)
+			AddTask(ProjectName, ProjectId)
 		case "delete", "del", "d":
-			DeleteTask(id, ProjectId)
+			DeleteTask(ProjectId)
+		case "rename", "r":
+			EditTask(ProjectId)
 		case "show", "s":
-			ShowTasks(id, ProjectId)
+			ShowTasks(ProjectId, filter)
+			PrintCommands("Tasks")
+		case "select", "sel":
+			SelectTask(state)
+			PrintCommands("Tasks")
+		case "done":
+			ChangeTaskStatus(ProjectId, TaskDone)
+			PrintCommands("Tasks")
+		case "reopen":
+			ChangeTaskStatus(ProjectId, TaskTodo)
+			PrintCommands("Tasks")
+		case "prio":
+			ChangeTaskPriority(ProjectId)
+			PrintCommands("Tasks")
+		case "due":
+			ChangeTaskDue(ProjectId)
+			PrintCommands("Tasks")
+		case "note":
+			ChangeTaskNotes(ProjectId)
+			PrintCommands("Tasks")
+		case "pomodoro", "pomo":
+			TogglePomodoroMode(state)
 			PrintCommands("Tasks")
 		case "quit", "q", "00":
-			SaveAndQuit(elapsed, reader, id, PauseTime)
 
-			// End loop
-			Tasksloop = false
+			// Save and go back to main menu
+			return SaveAndQuit(elapsed, state)
 
 		default:
 			ClearScreen()
@@ -657,84 +1730,79 @@ func TasksSwitch(reader *bufio.Reader, start time.Time, Activity string, id int,
 
 		}
 	}
+	return TaskScreen
 }
 
-func GetProjectName(id int, ProjectId int, data []JsonData) string {
-	// Find project name by projectid
-	CurrentProject := data[id].Projects[ProjectId]
-
-	// Save project name
-	pName := CurrentProject.Name
-
-	return pName
-}
-
-func SelectProjectId(id int, data []JsonData) int {
+// Ask for id of a project of the activity, false if cancelled
+func SelectProjectId(id int, data []JsonData) (Project, bool) {
 	// Bookmark
 loop:
 
 	// Ask for id
-	ProjectId := AskForId()
-
-	// Save maximum id
-	MaxID := len(data[id].Projects) - 1
-
-	// Error if id is bigger than MaxID or negative
-	if ProjectId > MaxID {
-
-		// ERROR message
-		Feedback("<< [ERROR] Max ID: [", MaxID, "] >>\n\n", true)
+	ProjectId, ok := AskForId()
+	if !ok {
+		return Project{}, false
+	}
 
-		// Go to bookmark
-		goto loop
+	// Project must belong to the activity
+	projectIndex := -1
+	index := FindIndexOf(id, data)
+	if index != -1 {
+		projectIndex = FindProjectIn(ProjectId, data[index])
+	}
 
-	} else if ProjectId < 0 {
+	if projectIndex == -1 {
 
 		// ERROR message
-		Feedback("<< [ERROR] id cant be negative!", "", " >>\n\n", true)
+		Feedback("<< [ERROR] Project ID: [", ProjectId, "] not found! >>\n\n", true)
 
 		// Go to bookmark
 		goto loop
 	}
 
-	return ProjectId
+	return data[index].Projects[projectIndex], true
 }
 
 // Add task
-func AddTask(pName string, SelectedIdint int, id int) {
-
-	reader := bufio.NewReader(os.Stdin)
-
-	// Ask task name
-	Feedback("\n<< Task name? >>", "", "\n=> ", false)
+func AddTask(pName string, projectId int) {
 
-	// Save answer
-	tName := Get_input(reader)
+	reader := app.In
 
-	// Get data from json
+	// Get data from json, the project may have been deleted from another terminal
 	data := OpenAndGetDataFromJson()
+	activityIndex, projectIndex, ok := FindProject(projectId, data)
+	if !ok {
+		Feedback("<< [ERROR] Project '", pName, "' not found! >>\n", true)
+		return
+	}
+	project := data[activityIndex].Projects[projectIndex]
+
+	// Bookmark
+loop:
 
-	// Empty slice for tasks
-	var tasks []string
+	// Ask task name
+	Feedback("\n<< Task name? >>", "", "\n=> ", false)
 
-	// Take old tasks and add to tasks slice
-	for key, value := range data[id].Projects {
-		if key == SelectedIdint {
-			tasks = value.Tasks
-		}
+	// Save answer, no task when the input ended
+	tName, ok := Get_input(reader)
+	if !ok {
+		return
 	}
 
-	// Append new task to tasks slice
-	tasks = append(tasks, tName)
+	// Check if task name already exist in the project
+	err := CheckTaskName(tName, project)
+	if err != nil {
 
-	// Append new tasks slice to data.json
-	data[id].Projects[SelectedIdint] = Project{Name: pName, Tasks: tasks}
+		// Tell user
+		Feedback("[ERROR] : ", err.Error(), "\n", true)
 
-	// Convert it back to byte
-	dataBytes := MarshalIndentToByte(data, "UpdateItem")
+		// Restart, go back to loop label
+		goto loop
+	}
 
-	// Override json file with updated data
-	WriteToFile(dataBytes)
+	// Append new task to the project, store gives the id
+	_, err = app.Store.AddTask(projectId, NewTask(tName))
+	ErrorHandling(err, "UpdateItem")
 
 	// Print about successful operation
 	PrintTaskAddedToProject(tName, pName)
@@ -743,12 +1811,77 @@ func AddTask(pName string, SelectedIdint int, id int) {
 	PrintCommands("Tasks")
 }
 
+/*<=================================================== Session functions ===================================================>*/
+
+// Time worked in one session (pause time removed), to the second
+func SessionDuration(session Session) time.Duration {
+	return session.End.Sub(session.Start) - time.Duration(session.PauseSeconds)*time.Second
+}
+
+// Minutes of one session as they are shown, rounded by the rounding policy
+func SessionMinutes(session Session) int {
+	return app.Rounding.Round(SessionDuration(session))
+}
+
+// Total time of an activity. Hours and Minutes in json hold the time
+// saved before sessions were recorded, everything else comes from sessions.
+func ActivityDuration(activity JsonData) time.Duration {
+	total := time.Duration(activity.Hours*60+activity.Minutes) * time.Minute
+	for _, session := range activity.Sessions {
+		total += app.Rounding.Counted(SessionDuration(session))
+	}
+	return total
+}
+
+// Total minutes of an activity as they are shown, only the sum is rounded
+func ActivityMinutes(activity JsonData) int {
+	return app.Rounding.Round(ActivityDuration(activity))
+}
+
+// Time of the sessions of a project, its tasks included
+func ProjectDuration(activity JsonData, projectId int) time.Duration {
+	total := time.Duration(0)
+	for _, session := range activity.Sessions {
+		if session.ProjectId != nil && *session.ProjectId == projectId {
+			total += app.Rounding.Counted(SessionDuration(session))
+		}
+	}
+	return total
+}
+
+// Time of the sessions of a task
+func TaskDuration(activity JsonData, taskId int) time.Duration {
+	total := time.Duration(0)
+	for _, session := range activity.Sessions {
+		if session.TaskId != nil && *session.TaskId == taskId {
+			total += app.Rounding.Counted(SessionDuration(session))
+		}
+	}
+	return total
+}
+
+// Whole seconds of a duration, the unit pauses are saved in
+func Seconds(duration time.Duration) int {
+	return int(duration.Round(time.Second) / time.Second)
+}
+
+// Split minutes to hours and minutes left
+func SplitMinutes(total int) (int, int) {
+	// Get hours out of all minutes
+	hours := total / 60
+
+	// Remove hours and get minutes left
+	minutes := total - (hours * 60)
+
+	return hours, minutes
+}
+
 /*<=================================================== Print functions ===================================================>*/
 
 // Print commands
 func Commandline_commands() {
 
-	// Print how much time is left till 22:00
+	// Print how much time is left in the workday
 	PrintTimeleft()
 
 	// Get data from json
@@ -764,35 +1897,19 @@ func Commandline_commands() {
 	PrintCommands("Activity")
 }
 
-// Calculate time left
+// Calculate time left in the workday
 func PrintTimeleft() {
 
-	// Time now
-	start := time.Now()
-
-	// Calculate minutes
-	MinutesNow := (start.Hour() * 60) + start.Minute()
-
-	// Target time 22:00
-	EndTime := 1320
-
-	// Calculate how many minutes till target time
-	MinutesTillEndTime := EndTime - MinutesNow
-
-	// If Minutes till end are negative
-	if MinutesTillEndTime < 0 {
-		MinutesTillEndTime *= -1
-		MinutesTillEndTime += EndTime
-	}
-
-	// Get Hours left
-	HoursLeft := MinutesTillEndTime / 60
+	// Working hours and day end from config
+	config, err := LoadConfig()
+	ErrorHandling(err, "PrintTimeleft")
 
-	// Get Minutes left
-	MinutesLeft := MinutesTillEndTime - (HoursLeft * 60)
+	// Running timer counts as logged today
+	timer, err := LoadTimer()
+	ErrorHandling(err, "PrintTimeleft")
 
 	// Print main info about programm
-	PrintProgramName(HoursLeft, MinutesLeft)
+	PrintProgramName(config.DayStatus(OpenAndGetDataFromJson(), timer, app.Now()))
 }
 
 func Feedback(first interface{}, middle interface{}, last interface{}, red bool) {
@@ -803,23 +1920,22 @@ func Feedback(first interface{}, middle interface{}, last interface{}, red bool)
 		ToPrint = []string{ColorRed(first), ColorWhite(middle), ColorRed(last)}
 	}
 
-	for _, v := range ToPrint {
-		fmt.Printf(v)
-	}
+	// One print, so nothing gets printed in between
+	app.Out.Print(ToPrint[0], ToPrint[1], ToPrint[2])
 }
 
 func ColorRed(item interface{}) string {
-	colorized := fmt.Sprintf(color.Colorize(color.Red, "%v"), item)
+	colorized := app.Out.Colorize(color.Red, item)
 	return colorized
 }
 
 func ColorGreen(item interface{}) string {
-	colorized := fmt.Sprintf(color.Colorize(color.Green, "%v"), item)
+	colorized := app.Out.Colorize(color.Green, item)
 	return colorized
 }
 
 func ColorWhite(item interface{}) string {
-	colorized := fmt.Sprintf(color.Colorize(color.White, "%v"), item)
+	colorized := app.Out.Colorize(color.White, item)
 	return colorized
 }
 
@@ -830,30 +1946,43 @@ func PrintElapsedTime(Activity string, elapsed time.Duration, start time.Time) {
 }
 
 // Print Program name and version
-func PrintProgramName(HoursLeft int, MinutesLeft int) {
+func PrintProgramName(status DayStatus) {
 
 	// Overall time
 	AppTime := OverallTimeSpentOnThisApp()
 
 	Feedback("\n<< VK TimeManager v", ProgramVersion, " ", false)
 	Feedback("(", AppTime," hours) >>\n", false)
-	Feedback("\n<< You have ", HoursLeft, " hours ", false)
-	Feedback("and ", MinutesLeft, " minutes left", false)
-	Feedback(" till ", "22:00", " >>\n\n", false)
+
+	if status.Off {
+		Feedback("\n<< ", "Day off", " today", false)
+	} else {
+		HoursLeft, MinutesLeft := SplitMinutes(status.Left)
+		Feedback("\n<< You have ", HoursLeft, " hours ", false)
+		Feedback("and ", MinutesLeft, " minutes left", false)
+		Feedback(" till ", status.End.Format("15:04"), "", false)
+	}
+
+	Feedback(" | logged today ", FormatMinutes(status.Logged), "", false)
+	if status.Working {
+		Feedback(" | capacity left ", FormatMinutes(status.Capacity), "", false)
+	}
+	Feedback("", "", " >>\n\n", false)
 
 }
 
 func OverallTimeSpentOnThisApp() int {
-	hoursSpent := gojsonq.New().File(filename).Sum("hours")
-	minutesSpent := gojsonq.New().File(filename).Sum("minutes")
-
-	// Get hours out of all minutes
-	GetHours := int(minutesSpent / 60)
+	// Get data from json
+	data := OpenAndGetDataFromJson()
 
-	// Remove hours and get minutes left
-	//GetMinutes := int(minutesSpent) - (GetHours * 60)
+	// Sum time of all activities, rounded once at the end
+	TimeSpent := time.Duration(0)
+	for _, value := range data {
+		TimeSpent += ActivityDuration(value)
+	}
 
-	OverallHours := int(hoursSpent) + GetHours
+	// Get hours out of all minutes
+	OverallHours, _ := SplitMinutes(app.Rounding.Round(TimeSpent))
 
 	return OverallHours
 }
@@ -866,7 +1995,7 @@ func PrintTaskAddedToProject(tName string, pName string) {
 func PrintCommands(command_type string) {
 
 	// Print command type
-	fmt.Printf(ColorGreen("\n<< ") + ColorWhite(command_type) + ColorGreen(" Commands >> \n"))
+	app.Out.Print(ColorGreen("\n<< ") + ColorWhite(command_type) + ColorGreen(" Commands >> \n"))
 
 	// Print commands by type
 	switch command_type {
@@ -878,7 +2007,7 @@ func PrintCommands(command_type string) {
 		PrintTasksCommands()
 	}
 
-	fmt.Printf(ColorGreen("\n=> "))
+	app.Out.Print(ColorGreen("\n=> "))
 }
 
 func PrintActivityCommands() {
@@ -886,11 +2015,20 @@ func PrintActivityCommands() {
 	Feedback("<< | <", "top", "> or ", false)
 	Feedback("<", "t", ">", false)
 
+	Feedback(" | <", "report", ">", false)
+
 	Feedback(" | <", "add", "> or ", false)
 	Feedback("<", "a", ">", false)
 
 	Feedback(" | <", "delete", "> or ", false)
-	Feedback("<", "del", ">", false)
+	Feedback("<", "del", "> archive", false)
+	Feedback(" | <", "del --purge", ">", false)
+	Feedback(" | <", "unarchive", ">", false)
+	Feedback(" | <", "edit", "> or ", false)
+	Feedback("<", "rename", ">", false)
+	Feedback(" | <", "log", "> time", false)
+	Feedback(" | <", "undo", ">", false)
+	Feedback(" | <", "redo", ">", false)
 	Feedback(" | <", "quit", "> or ", false)
 	Feedback("<", "q", "> or ", false)
 	Feedback("<", "00", ">  | >>", false)
@@ -900,13 +2038,20 @@ func PrintProjectsCommands() {
 	Feedback("<< | <", "add", "> or ", false)
 	Feedback("<", "a", ">", false)
 	Feedback(" | <", "delete", "> or ", false)
-	Feedback("<", "del", ">", false)
+	Feedback("<", "del", "> archive", false)
+	Feedback(" | <", "del --purge", ">", false)
+	Feedback(" | <", "unarchive", "> or ", false)
+	Feedback("<", "u", ">", false)
+	Feedback(" | <", "rename", "> or ", false)
+	Feedback("<", "r", ">", false)
 	Feedback(" | <", "projects", "> or ", false)
 	Feedback("<", "p", ">", false)
 	Feedback(" | <", "select", "> or ", false)
 	Feedback("<", "s", "> | >>", false)
 	Feedback("\n<< | <", "pause", "> or ", false)
 	Feedback("<", "+", ">", false)
+	Feedback(" | <", "pomodoro", "> or ", false)
+	Feedback("<", "pomo", ">", false)
 	Feedback(" | <", "quit", "> or ", false)
 	Feedback("<", "q", "> or ", false)
 	Feedback("<", "00", "> | >>", false)
@@ -917,8 +2062,18 @@ func PrintTasksCommands() {
 	Feedback("<", "a", ">", false)
 	Feedback(" | <", "delete", "> or ", false)
 	Feedback("<", "del", ">", false)
-	Feedback(" | <", "show", "> or ", false)
-	Feedback("<", "s", "> | >>", false)
+	Feedback(" | <", "rename", "> or ", false)
+	Feedback("<", "r", ">", false)
+	Feedback(" | <", "show [all|done|overdue]", "> or ", false)
+	Feedback("<", "s", ">", false)
+	Feedback(" | <", "select", "> or ", false)
+	Feedback("<", "sel", "> | >>", false)
+	Feedback("\n<< | <", "done", ">", false)
+	Feedback(" | <", "reopen", ">", false)
+	Feedback(" | <", "prio", ">", false)
+	Feedback(" | <", "due", ">", false)
+	Feedback(" | <", "note", ">", false)
+	Feedback(" | <", "pomodoro", "> | >>", false)
 	Feedback("\n<< | <", "back", "> or ", false)
 	Feedback("<", "b", ">", false)
 	Feedback(" | <", "quit", "> or ", false)
@@ -930,16 +2085,26 @@ func PrintAllActivities(data []JsonData) {
 
 	Feedback("<< ", " What do you want to do now? ", ">>\n", false)
 
-	// Print all activities
-	for _, component := range data {
-		Feedback("<< [", component.Hours, "h:", false)
-		Feedback("", component.Minutes, "m] ", false)
+	// Print all activities, archived ones are hidden
+	for _, component := range VisibleActivities(data) {
+		hours, minutes := SplitMinutes(ActivityMinutes(component))
+		Feedback("<< [", hours, "h:", false)
+		Feedback("", minutes, "m] ", false)
 		Feedback("", component.Activity, " || ", false)
 		Feedback("", component.Short, "(", false)
 		Feedback("", component.Id, ") >>\n", false)
 	}
 }
 
+// Print journal entries numbered from 1
+func PrintJournalEntries(entries []JournalEntry) {
+	for number, entry := range entries {
+		Feedback("<< [", number+1, "] ", false)
+		Feedback("", entry.Time.Format("02.01.2006 15:04"), " ", false)
+		Feedback("", entry.Description, " >>\n", false)
+	}
+}
+
 // Tell user about started activity
 func PrintActivityInfo(id int, data []JsonData, Activity string, start time.Time, hours int, minutes int) {
 
@@ -950,100 +2115,102 @@ func PrintActivityInfo(id int, data []JsonData, Activity string, start time.Time
 	//Feedback("\n<< Nr of Projects: ", len(data[id].Projects), " >>\n", false)
 }
 
-// Print projects
+// Print projects of the activity with id
 func PrintProjects(id int) {
 
 	// Get data from json
 	data := OpenAndGetDataFromJson()
 
-	Feedback("\n<< My Projects (", len(data[id].Projects), ") >>\n", false)
+	// Find activity by id
+	index := FindIndexOf(id, data)
+	if index == -1 {
+		return
+	}
+
+	// Archived projects are hidden
+	projects := VisibleProjects(data[index])
+
+	Feedback("\n<< My Projects (", len(projects), ") >>\n", false)
 
 	// Print all projects id --> name --> tasks
-	for key, value := range data[id].Projects {
+	for _, value := range projects {
 
-		Feedback("<< (", key, ")'", false)
+		Feedback("<< (", value.Id, ")'", false)
 		Feedback("", value.Name, "' | (", false)
-		Feedback("", len(value.Tasks), " Tasks) >>\n", false)
+		Feedback("", len(value.Tasks), " Tasks) | ", false)
+		Feedback("", FormatMinutes(app.Rounding.Round(ProjectDuration(data[index], value.Id))), " >>\n", false)
 
 	}
+
+	// Tell about hidden projects
+	if archived := len(data[index].Projects) - len(projects); archived > 0 {
+		Feedback("<< ", archived, " archived (unarchive) >>\n", false)
+	}
 }
 
 /*<=================================================== Small Help functions ===================================================>*/
 
-// Ask before delete
-func DeleteCheckQuestion(name string) bool {
-
-	if name != "" {
-		Feedback("<< Do you really want to delete '", name, "' ??? >>", true)
-	}
+// Ask before the time is thrown away, true when no is typed. False second when the input ended.
+func DeleteCheckQuestion() (bool, bool) {
 
 	// Get reader
-	reader := bufio.NewReader(os.Stdin)
+	reader := app.In
 
 	// Get input
-	input := Get_input(reader)
+	input, ok := Get_input(reader)
 
 	// Check if 'no' is entered
 	check := "no" == input
 
-	return check
+	return check, ok
 }
 
-func PressEnter() {
-	var command string
-	fmt.Scanln(&command)
-}
+// Ask a question that only typing yes answers with yes
+func AskYes(question string) bool {
 
-func SaveAndQuit(elapsed time.Duration, reader *bufio.Reader, id int, PauseTime int) {
+	Feedback("<< ", question, " (type yes) >>\n=> ", true)
 
-	// Tell user elapsed time
-	Feedback("\n<< You have spent ", elapsed, " >>\n", false)
+	// End of input means no
+	input, _ := app.In.ReadString('\n')
 
-	// Ask for save time
-	Save_time(reader, elapsed, id, PauseTime)
+	return strings.TrimRight(input, "\r\n") == "yes"
 }
 
-// Check db for data and return bool
-func CheckDBForData(data []JsonData) bool {
-	// Dont get id if db is empty
-	GetId := false
-
-	// Get id if db is not empty
-	if len(data) != 0 {
-		GetId = true
-	}
+// Ask before deleting for good, only typing yes deletes
+func PurgeCheckQuestion(name string) bool {
 
-	return GetId
-}
+	Feedback("<< Delete '", name, "' and all its time for good? (type yes) >>\n=> ", true)
 
-// Open and get data
-func OpenAndGetDataFromJson() []JsonData {
-	// Open json file
-	file := ReadFile()
+	// End of input means no
+	input, _ := app.In.ReadString('\n')
 
-	// Get data and store it in data variable
-	data := ConvertToJsonDataStruct(file)
+	return strings.TrimRight(input, "\r\n") == "yes"
+}
 
-	return data
+func PressEnter() {
+	Get_input(app.In)
 }
 
-// Make dir and data.json if not exist
-func MakeDirAndJson() {
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
+func SaveAndQuit(elapsed time.Duration, state *CommandlineState) Screen {
+
+	// Tell user elapsed time
+	Feedback("\n<< You have spent ", elapsed, " >>\n", false)
 
-		// Make new directory data
-		_ = os.Mkdir("data", 0700)
+	// Ask for save time
+	return Save_time(elapsed, state)
+}
 
-		f, err := os.Create(filename)
-		ErrorHandling(err, "MakeDirAndJson")
+// Open and get data
+func OpenAndGetDataFromJson() []JsonData {
+	// Get data from store
+	data, err := app.Store.Activities()
+	ErrorHandling(err, "OpenAndGetDataFromJson")
 
-		defer f.Close()
-		fmt.Fprintln(f, "[]")
-	}
+	return data
 }
 
-// Ask for id
-func AskForId() int {
+// Ask for id, false if q or 00 is entered or the input ended
+func AskForId() (int, bool) {
 
 	// Bookmark
 loop:
@@ -1052,15 +2219,13 @@ loop:
 	Feedback("<< ", "", "ID: ", true)
 
 	// Get reader
-	reader := bufio.NewReader(os.Stdin)
+	reader := app.In
 
 	// Get input as string
-	GetIdString := Get_input(reader)
+	GetIdString, ok := Get_input(reader)
 
-	if GetIdString == "q" || GetIdString == "00" {
-		ClearScreen()
-		Feedback("<< ", "Exiting to commandline", " >>", true)
-		Commandline()
+	if !ok || GetIdString == "q" || GetIdString == "00" {
+		return 0, false
 	}
 
 	// Convert string to int
@@ -1076,118 +2241,55 @@ loop:
 		goto loop
 	}
 
-	return GetId
+	return GetId, true
 }
 
-// Encrypt new data and construct a WebsiteData struct for adding it to json file
-func ConvertAnswersToJsonData(Activity_Name string, Activity_Name_short string, GetLastid bool) JsonData {
-
-	// ID is 0 if database is empty
-	Id := 0
-
-	// If db is not empty get last id + 1
-	if GetLastid {
-		Id = GetLastId(filename)
-	}
+// Construct a JsonData struct for adding it to store, store gives the id
+func ConvertAnswersToJsonData(Activity_Name string, Activity_Name_short string) JsonData {
 
 	ValuesToAdd := JsonData{
-		Id:       Id,
 		Activity: Activity_Name,
 		Short:    Activity_Name_short,
 		Hours:    0,
 		Minutes:  0,
 		Projects: []Project{},
+		Sessions: []Session{},
 	}
 
 	return ValuesToAdd
 }
 
-// Get max id and add +1
-func GetLastId(filename string) int {
-	BiggestId := gojsonq.New().File(filename).Max("id")
-	id := 0
-	if BiggestId == 0 {
-		id = 1
-	} else {
-		id = int(BiggestId + 1)
-	}
-
-	return id
-}
-
-// Find Real id to prevent error when some activity is deleted
-func FindRealId(id int) int {
-	FindAllitems := gojsonq.New().File(filename).Count()
-
-	Maxid := int(FindAllitems) - 1
-
-	// if entered id is bigger then maximum possible id change it
-	if id > Maxid {
-		id = Maxid
-	}
-
-	return id
-}
-
-// Find index
-func FindIndexOf(element int, data []JsonData) int {
-	for index, Struct := range data {
-		if element == Struct.Id {
-			return index
+// Check if name is a commandline command
+func IsReservedWord(name string) bool {
+	for _, word := range reservedWords {
+		if name == word {
+			return true
 		}
 	}
-	return -1 //not found.
-}
-
-// MarshalIndent []WebsiteData array to []byte (Makes json pretty)
-func MarshalIndentToByte(data []JsonData, location string) []byte {
-	dataBytes, err := json.MarshalIndent(data, "", "  ")
-	ErrorHandling(err, location)
-
-	return dataBytes
-}
-
-// Open file
-func ReadFile() []byte {
-	file, err := ioutil.ReadFile("data/data.json")
-	ErrorHandling(err, "ReadFile")
-	return file
+	return false
 }
 
-// Write to file (override)
-func WriteToFile(dataBytes []byte) {
-	var err error
-	err = ioutil.WriteFile("data/data.json", dataBytes, 0644)
-	ErrorHandling(err, "WriteToFile")
-}
-
-// Convert []byte to WebsiteData struct (array)
-func ConvertToJsonDataStruct(body []byte) []JsonData {
-
-	JsonDataStruct := []JsonData{}
-
-	err := json.Unmarshal(body, &JsonDataStruct)
-	ErrorHandling(err, "ConvertToJsonDataStruct")
+// Check answer, false when there is nothing more to read (Ctrl+D or end of piped input).
+// Every prompt gives up then and the screens return QuitScreen.
+func Get_input(reader *bufio.Reader) (string, bool) {
+	// Read the answer
+	input, err := reader.ReadString('\n')
 
-	return JsonDataStruct
-}
+	// Nothing more to read
+	if err != nil && input == "" {
+		return "", false
+	}
 
-// Check answer
-func Get_input(reader *bufio.Reader) string {
-	// Read the answer
-	input, _ := reader.ReadString('\n')
-	// convert CRLF to LF
-	input = strings.Replace(input, "\r\n", "", -1)
+	// Remove line end, LF on unix and CRLF on windows
+	input = strings.TrimRight(input, "\r\n")
 
-	return input
+	return input, true
 }
 
 // Clear screen
 func ClearScreen() {
-	clearScreen := exec.Command("cmd", "/c", "cls")
-	clearScreen.Stdout = os.Stdout
-	err := clearScreen.Run()
-	ErrorHandling(err, "ClearScreen")
+	// Nothing to clear when output goes to a file or pipe
+	app.Out.Clear()
 }
 
 // Handle Errors
@@ -1196,9 +2298,3 @@ func ErrorHandling(err error, location string) {
 		Feedback(location, ":", err.Error(), true)
 	}
 }
-
-// quit
-func quit() {
-	ClearScreen()
-	os.Exit(0)
-}
@@ -0,0 +1,944 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Clock of the test app, only moved by the script
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// Typed input, one line per read so the clock moves between commands.
+// Lines like "+25m" are not typed, they move the clock forward.
+type scriptReader struct {
+	lines []string
+	clock *testClock
+}
+
+func (r *scriptReader) Read(p []byte) (int, error) {
+	for len(r.lines) > 0 {
+		line := r.lines[0]
+		wait, err := time.ParseDuration(strings.TrimPrefix(line, "+"))
+		if !strings.HasPrefix(line, "+") || err != nil {
+			break
+		}
+		r.clock.now = r.clock.now.Add(wait)
+		r.lines = r.lines[1:]
+	}
+
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.lines[0]+"\n")
+	r.lines = r.lines[1:]
+	return n, nil
+}
+
+var testStart = time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+
+// Empty data directory with the app reading from script, returns the clock
+func NewTestApp(t *testing.T, activities ...string) *testClock {
+	t.Helper()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewJsonStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := app
+	clock := &testClock{testStart}
+	// Changes made by scripts are journaled, the activities added below are not
+	app = NewApp(strings.NewReader(""), ioutil.Discard, clock.Now, NewJournalStore(store))
+	t.Cleanup(func() {
+		store.Close()
+		app = old
+		os.Chdir(dir)
+	})
+
+	// Activities given as "name short"
+	for _, activity := range activities {
+		fields := strings.Fields(activity)
+		_, err := store.AddActivity(ConvertAnswersToJsonData(fields[0], fields[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return clock
+}
+
+// Run commandline with script and return everything it printed
+func RunScript(t *testing.T, clock *testClock, script ...string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	app.In = bufio.NewReader(&scriptReader{script, clock})
+	app.Out = NewTerminal(&out)
+
+	RunCommandline()
+	return out.String()
+}
+
+// Activities in the data file
+func ReadDataFile(t *testing.T) []JsonData {
+	t.Helper()
+
+	file, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data DataFile
+	err = json.Unmarshal(file, &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Version != SchemaVersion {
+		t.Fatalf("data file version %d, want %d", data.Version, SchemaVersion)
+	}
+	data.FillSessionNames()
+	return data.Activities
+}
+
+func AssertOutput(t *testing.T, output string, want ...string) {
+	t.Helper()
+	for _, text := range want {
+		if !strings.Contains(output, text) {
+			t.Errorf("output misses %q:\n%s", text, output)
+		}
+	}
+}
+
+func AssertSession(t *testing.T, session Session, start time.Time, end time.Time, pause int, project string) {
+	t.Helper()
+	if !session.Start.Equal(start) || !session.End.Equal(end) || session.PauseSeconds != pause*60 || session.Project != project {
+		t.Errorf("session %s - %s pause %ds project %q, want %s - %s pause %dm project %q",
+			session.Start.Format("15:04"), session.End.Format("15:04"), session.PauseSeconds, session.Project,
+			start.Format("15:04"), end.Format("15:04"), pause, project)
+	}
+}
+
+func AssertNoTimer(t *testing.T) {
+	t.Helper()
+	timer, err := LoadTimer()
+	if err != nil || timer != nil {
+		t.Errorf("timer %+v (%v), want none", timer, err)
+	}
+}
+
+/*<=================================================== Activities ===================================================>*/
+
+func TestAddActivity(t *testing.T) {
+	clock := NewTestApp(t)
+
+	output := RunScript(t, clock, "a", "coding", "c", "q")
+
+	AssertOutput(t, output, "WARNING: No data in database", "coding")
+	data := ReadDataFile(t)
+	if len(data) != 1 || data[0].Activity != "coding" || data[0].Short != "c" || data[0].Id != 0 {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+func TestAddActivityRejectsTakenNames(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "a", "coding", "", "top", "writing", "c", "w", "q")
+
+	AssertOutput(t, output, "'coding' already exist in db", "name can't be empty", "'top' is a command", "'c' already exist in db")
+	data := ReadDataFile(t)
+	if len(data) != 2 || data[1].Activity != "writing" || data[1].Short != "w" {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+func TestArchiveActivity(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	output := RunScript(t, clock, "del", "x", "0", "", "c", "q")
+
+	AssertOutput(t, output, "ID must be a number!", "ID: '0' archived!", "[coding] is archived!")
+	data := ReadDataFile(t)
+	if len(data) != 2 || !data[0].Archived || data[1].Archived {
+		t.Fatalf("data %+v", data)
+	}
+
+	output = RunScript(t, clock, "unarchive", "1", "unarchive", "0", "q")
+
+	AssertOutput(t, output, "[archived] coding", "ID: '1' is not archived!", "'coding' restored!")
+	if data := ReadDataFile(t); data[0].Archived {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+func TestArchivedTimeCounts(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	RunScript(t, clock, "c", "+90m", "q", "", "del", "0", "", "q")
+
+	data := ReadDataFile(t)
+	if OverallTimeSpentOnThisApp() != 1 || len(BuildReport(data, testStart, testStart.Add(time.Hour*2)).Activities) != 1 {
+		t.Fatalf("archived time is not counted %+v", data)
+	}
+}
+
+func TestPurgeActivity(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	output := RunScript(t, clock, "del --purge", "0", "no", "del --purge", "0", "yes", "", "q")
+
+	AssertOutput(t, output, "and all its time for good?", "Cancelled", "ID: '0' Removed!")
+	data := ReadDataFile(t)
+	if len(data) != 1 || data[0].Activity != "writing" {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "nope", "q")
+
+	AssertOutput(t, output, "No such command or activity!")
+}
+
+/*<=================================================== Timer ===================================================>*/
+
+func TestStartAndSave(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "+90m", "q", "", "q")
+
+	AssertOutput(t, output, "Starting coding at 02.03.2026 09:00:00", "You have spent 1h30m0s", "LAST TIME HAS BEEN SAVED")
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 1 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart, testStart.Add(90*time.Minute), 0, "")
+	AssertNoTimer(t)
+}
+
+func TestPauseAndSave(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "+30m", "+", "+15m", "", "+30m", "q", "", "q")
+
+	AssertOutput(t, output, "[coding] paused!", "Unpaused [Pause time: 15m0s]", "You have spent 1h15m0s")
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 1 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart, testStart.Add(75*time.Minute), 15, "")
+	if SessionMinutes(sessions[0]) != 60 {
+		t.Errorf("session minutes %d, want 60", SessionMinutes(sessions[0]))
+	}
+}
+
+func TestSecondsAddUp(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	// Three sessions of 40 seconds, one with a 20 second pause
+	output := RunScript(t, clock, "c", "+40s", "q", "", "c", "+40s", "q", "", "c", "+20s", "+", "+20s", "", "+20s", "q", "", "report", "", "q")
+
+	AssertOutput(t, output, "(total 0h 02m)")
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 3 || sessions[2].PauseSeconds != 20 || SessionDuration(sessions[2]) != 40*time.Second {
+		t.Fatalf("sessions %+v", sessions)
+	}
+}
+
+func TestRoundingPolicy(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	err := ioutil.WriteFile(configFilename, []byte(`{"rounding": {"minutes": 15, "mode": "up", "per": "session"}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Rounding = config.Rounding
+
+	// 20 and 5 minutes are billed as 30 and 15, the sessions keep the real time
+	output := RunScript(t, clock, "c", "+20m", "q", "", "c", "+5m", "q", "", "report", "", "q")
+
+	AssertOutput(t, output, "(total 0h 45m)")
+	sessions := ReadDataFile(t)[0].Sessions
+	AssertSession(t, sessions[1], testStart.Add(20*time.Minute), testStart.Add(25*time.Minute), 0, "")
+
+	app.Rounding.Per = "total"
+	if minutes := ActivityMinutes(ReadDataFile(t)[0]); minutes != 30 {
+		t.Errorf("minutes %d, want 30", minutes)
+	}
+}
+
+// Shift starts at its clock time on days the clocks change
+func TestShiftOnDstChange(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	config := DefaultConfig()
+	config.WorkingHours["sunday"] = WorkingHours{Start: "09:00", End: "17:00"}
+
+	for _, day := range []time.Time{time.Date(2026, 3, 29, 0, 0, 0, 0, berlin), time.Date(2026, 10, 25, 0, 0, 0, 0, berlin)} {
+		start, end, ok := config.Shift(day)
+		if !ok || start.Hour() != 9 || end.Hour() != 17 {
+			t.Errorf("%s: shift %s - %s", day.Format("02.01.2006"), start.Format("15:04"), end.Format("15:04"))
+		}
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "+20m", "q", "no", "", "q")
+
+	AssertOutput(t, output, "LAST TIME NOT SAVED")
+	if sessions := ReadDataFile(t)[0].Sessions; len(sessions) != 0 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertNoTimer(t)
+}
+
+func TestOnlyOneTimer(t *testing.T) {
+	NewTestApp(t, "coding c", "writing w")
+
+	var out bytes.Buffer
+	app.Out = NewTerminal(&out)
+
+	// Timer started in another terminal after the menu was printed
+	err := SaveTimer(Timer{ActivityId: 1, Activity: "writing", Start: testStart})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := NewCommandlineState()
+	if next := ActivitySwitch("c", ReadDataFile(t), state); next != SameScreen {
+		t.Errorf("next screen %d, want SameScreen", next)
+	}
+	AssertOutput(t, out.String(), "[writing] is already running!")
+}
+
+func TestStopRightAfterStartSavesNothing(t *testing.T) {
+	NewTestApp(t, "coding c")
+
+	var out bytes.Buffer
+	app.Out = NewTerminal(&out)
+
+	if code := RunSubcommand([]string{"start", "c"}); code != 0 {
+		t.Fatalf("start exit code %d", code)
+	}
+	if code := RunSubcommand([]string{"stop"}); code != 0 {
+		t.Fatalf("stop exit code %d", code)
+	}
+	AssertOutput(t, out.String(), "[coding] stopped, no time worked to save")
+	if sessions := ReadDataFile(t)[0].Sessions; len(sessions) != 0 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertNoTimer(t)
+}
+
+// Time of a project or task deleted while the timer ran goes to what is left
+func TestStopAfterTargetDeleted(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+	app.Out = NewTerminal(ioutil.Discard)
+
+	project, err := app.Store.AddProject(0, Project{Name: "api", Tasks: []Task{{Name: "docs"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		t.Helper()
+		if code := RunSubcommand(args); code != 0 {
+			t.Fatalf("%v exit code %d", args, code)
+		}
+	}
+
+	run("start", "c", "--project", "api", "--task", "docs")
+	clock.now = clock.now.Add(20 * time.Minute)
+	if err := app.Store.DeleteTask(project.Tasks[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	run("stop")
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 1 || sessions[0].TaskId != nil {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart, testStart.Add(20*time.Minute), 0, "api")
+
+	// Sessions of the project go with it
+	run("start", "c", "--project", "api")
+	clock.now = clock.now.Add(10 * time.Minute)
+	if err := app.Store.DeleteProject(project.Id); err != nil {
+		t.Fatal(err)
+	}
+	run("stop")
+
+	sessions = ReadDataFile(t)[0].Sessions
+	if len(sessions) != 1 || sessions[0].ProjectId != nil || sessions[0].TaskId != nil {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart.Add(20*time.Minute), testStart.Add(30*time.Minute), 0, "")
+	AssertNoTimer(t)
+}
+
+func TestProjectAndTask(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "a", "api", "s", "5", "0", "a", "docs", "+45m", "q", "", "q")
+
+	AssertOutput(t, output, "Project 'api' added to db!", "Project ID: [5] not found!", "Project: api", "Task 'docs' added to project 'api'!")
+	activity := ReadDataFile(t)[0]
+	if len(activity.Projects) != 1 || activity.Projects[0].Name != "api" || len(activity.Projects[0].Tasks) != 1 || activity.Projects[0].Tasks[0].Name != "docs" {
+		t.Fatalf("projects %+v", activity.Projects)
+	}
+	if len(activity.Sessions) != 1 {
+		t.Fatalf("sessions %+v", activity.Sessions)
+	}
+	AssertSession(t, activity.Sessions[0], testStart, testStart.Add(45*time.Minute), 0, "api")
+}
+
+func TestAddTaskRejectsTakenNames(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "a", "api", "s", "0", "a", "", "docs", "a", "docs", "tests", "q", "no", "", "q")
+
+	AssertOutput(t, output, "task name can't be empty", "Task 'docs' already exist in 'api'")
+	tasks := ReadDataFile(t)[0].Projects[0].Tasks
+	if len(tasks) != 2 || tasks[0].Name != "docs" || tasks[1].Name != "tests" {
+		t.Fatalf("tasks %+v", tasks)
+	}
+}
+
+func TestSwitchProjectSplitsSession(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "a", "api", "a", "web", "s", "0", "+30m", "b", "+10m", "s", "1", "a", "docs", "sel", "0", "+20m", "q", "",
+		"c", "p", "s", "1", "q", "no", "", "q")
+
+	AssertOutput(t, output, "Saved 0h 30m for 'coding / api'", "Saved 0h 10m for 'coding'", "Task: docs",
+		"'api' | (0 Tasks) | 0h 30m", "'web' | (1 Tasks) | 0h 20m", "'docs' 0h 20m")
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 3 || sessions[2].Task != "docs" {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart, testStart.Add(30*time.Minute), 0, "api")
+	AssertSession(t, sessions[1], testStart.Add(30*time.Minute), testStart.Add(40*time.Minute), 0, "")
+	AssertSession(t, sessions[2], testStart.Add(40*time.Minute), testStart.Add(time.Hour), 0, "web")
+}
+
+func TestTaskStatusAndPriority(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "a", "api", "s", "0", "a", "docs", "a", "tests",
+		"prio", "1", "urgent", "high", "due", "0", "01.03.2026", "sel", "0", "done", "1", "s", "s done", "reopen", "1", "+10m", "q", "", "q")
+
+	AssertOutput(t, output, "unknown priority 'urgent'", "Task 'tests' priority: high", "Task: docs", "Task 'tests' done!",
+		"'docs' 0h 00m | doing | due 01.03.2026 (overdue)", "1 done (show done)",
+		"'tests' 0h 00m | done | high | done 02.03.2026 09:00", "Task 'tests' reopened!")
+	activity := ReadDataFile(t)[0]
+	docs, tests := activity.Projects[0].Tasks[0], activity.Projects[0].Tasks[1]
+	if docs.Status != TaskDoing || docs.Due == nil || docs.Created == nil || !docs.Created.Equal(testStart) {
+		t.Errorf("docs %+v", docs)
+	}
+	if tests.Status != TaskTodo || tests.Priority != 3 || tests.Completed != nil {
+		t.Errorf("tests %+v", tests)
+	}
+	if len(activity.Sessions) != 1 || activity.Sessions[0].Task != "docs" {
+		t.Fatalf("sessions %+v", activity.Sessions)
+	}
+}
+
+func TestPomodoroBreaksArePause(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	// Breaks after 25m, the long one after the 4th pomodoro while nobody looked
+	output := RunScript(t, clock, "c --pomodoro", "a", "api", "s", "0", "a", "docs", "sel", "0",
+		"+25m", "s", "+5m", "s", "+2h", "s", "q", "", "q")
+
+	AssertOutput(t, output, "Pomodoro 1 done at 09:25, take a 5 minute break", "Break over at 09:30, back to work for 25 minutes",
+		"Pomodoro 4 done at 10:55, take a long break of 15 minutes", "Break over at 11:10", "'docs' 0h 00m | doing | 1 pomodoros")
+	activity := ReadDataFile(t)[0]
+	if len(activity.Sessions) != 1 || activity.Projects[0].Tasks[0].Pomodoros != 4 {
+		t.Fatalf("activity %+v", activity)
+	}
+	AssertSession(t, activity.Sessions[0], testStart, testStart.Add(150*time.Minute), 30, "api")
+	AssertNoTimer(t)
+
+	// Counted pomodoros are not for undo
+	journal, err := LoadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range journal.Undo {
+		if strings.HasPrefix(entry.Description, "change task") || strings.Contains(entry.Description, "pomodoro") {
+			t.Errorf("journaled '%s'", entry.Description)
+		}
+	}
+}
+
+// Counted pomodoros don't stand in the way of undo, and undo keeps them
+func TestUndoPastPomodoro(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	RunScript(t, clock, "c --pomodoro", "a", "api", "s", "0", "a", "docs", "sel", "0", "+25m", "s", "+5m", "s", "q", "", "q")
+	journal, err := LoadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ReplayJournal(1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activity := ReadDataFile(t)[0]
+	if len(activity.Sessions) != 0 || activity.Projects[0].Tasks[0].Pomodoros != 1 {
+		t.Fatalf("activity %+v", activity)
+	}
+
+	_, err = ReplayJournal(len(journal.Undo)-1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activity := ReadDataFile(t)[0]; len(activity.Projects) != 0 {
+		t.Fatalf("activity %+v", activity)
+	}
+}
+
+// Notices of the pomodoro watcher never land inside a line of the commandline
+func TestOutputIsPrintedWhole(t *testing.T) {
+	NewTestApp(t)
+	var out bytes.Buffer
+	app.Out = NewTerminal(&out)
+
+	until := announced.until
+	announced.until = time.Time{}
+	t.Cleanup(func() { announced.until = until })
+
+	done := make(chan bool)
+	go func() {
+		for i := 1; i <= 200; i++ {
+			AnnouncePomodoro([]PomodoroTransition{{Phase: PhaseBreak, At: testStart.Add(time.Duration(i) * time.Minute), Done: i}})
+		}
+		done <- true
+	}()
+	for i := 0; i < 200; i++ {
+		Feedback("<< first ", "middle", " last >>\n", false)
+	}
+	<-done
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(line, "first") && line != "<< first middle last >>" {
+			t.Fatalf("line %q", line)
+		}
+	}
+}
+
+func TestBackFromProject(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	RunScript(t, clock, "c", "a", "api", "s", "0", "b", "+10m", "q", "", "q")
+
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 1 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart, testStart.Add(10*time.Minute), 0, "")
+}
+
+func TestDeleteProjectAndTask(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	// Anything but yes keeps the task
+	RunScript(t, clock, "c", "a", "api", "a", "web", "s", "0", "a", "one", "a", "two", "d", "1", "", "d", "1", "y", "d", "0", "yes",
+		"b", "d --purge", "1", "yes", "q", "no", "", "q")
+
+	projects := ReadDataFile(t)[0].Projects
+	if len(projects) != 1 || projects[0].Name != "api" || len(projects[0].Tasks) != 1 || projects[0].Tasks[0].Name != "two" {
+		t.Fatalf("projects %+v", projects)
+	}
+}
+
+// Time belongs to the project id, a new project with the name of a purged one starts at zero
+func TestPurgedProjectNameStartsAtZero(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	RunScript(t, clock, "c", "a", "api", "s", "0", "+30m", "q", "", "q")
+	output := RunScript(t, clock, "c", "d --purge", "0", "yes", "a", "api", "q", "", "q")
+
+	AssertOutput(t, output, "Project 'api' added to db!")
+	activity := ReadDataFile(t)[0]
+	if len(activity.Projects) != 1 || activity.Projects[0].Id != 1 || ActivityDuration(activity) != 0 {
+		t.Fatalf("activity %+v", activity)
+	}
+	if duration := ProjectDuration(activity, activity.Projects[0].Id); duration != 0 {
+		t.Errorf("new project 'api' has %s, want none", duration)
+	}
+}
+
+func TestArchiveProject(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "a", "api", "a", "web", "d", "0", "p", "s", "0", "u", "0", "s", "0", "+5m", "q", "", "q")
+
+	AssertOutput(t, output, "Project 'api' archived!", "My Projects (1)", "1 archived", "Project 'api' is archived!", "Project 'api' restored!", "Project: api")
+	activity := ReadDataFile(t)[0]
+	if len(activity.Projects) != 2 || activity.Projects[0].Archived {
+		t.Fatalf("projects %+v", activity.Projects)
+	}
+}
+
+func TestIdsStayAfterDelete(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w", "reading r")
+
+	// Delete coding, id 1 is still writing and the new activity does not get an old id
+	RunScript(t, clock, "del --purge", "0", "yes", "", "1", "+10m", "q", "", "a", "drawing", "d", "q")
+
+	data := ReadDataFile(t)
+	if len(data) != 3 || data[0].Activity != "writing" || data[2].Id != 3 {
+		t.Fatalf("activities %+v", data)
+	}
+	if len(data[0].Sessions) != 1 || len(data[1].Sessions) != 0 {
+		t.Fatalf("sessions %+v %+v", data[0].Sessions, data[1].Sessions)
+	}
+}
+
+func TestRenameActivity(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	output := RunScript(t, clock, "edit", "0", "w", "undo", "code", "", "q")
+
+	AssertOutput(t, output, "'w' already exist in db", "'undo' is a command", "'coding' renamed to 'code' (c)!")
+	data := ReadDataFile(t)
+	if data[0].Activity != "code" || data[0].Short != "c" || data[1].Activity != "writing" {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+func TestRenameProjectAndTask(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "a", "api", "a", "web", "r", "0", "web", "backend", "s", "0", "a", "one", "a", "two", "r", "0", "two", "", "r", "0", "first", "+30m", "q", "", "q")
+
+	AssertOutput(t, output, "Project 'web' already exist in db", "Project 'api' renamed to 'backend'!", "Task 'two' already exist in 'backend'", "Task 'one' renamed to 'first'!")
+	activity := ReadDataFile(t)[0]
+	if activity.Projects[0].Name != "backend" || activity.Projects[0].Tasks[0].Name != "first" || activity.Projects[0].Tasks[0].Id != 0 {
+		t.Fatalf("projects %+v", activity.Projects)
+	}
+
+	// Saved time moves with the project, undo puts the old name back
+	RunScript(t, clock, "c", "r", "0", "server", "q", "", "q")
+
+	activity = ReadDataFile(t)[0]
+	if len(activity.Sessions) == 0 || activity.Sessions[0].Project != "server" || ProjectDuration(activity, 0) < 30*time.Minute {
+		t.Fatalf("sessions %+v", activity.Sessions)
+	}
+
+	// Quitting saved the few seconds after the rename too
+	output = RunScript(t, clock, "undo", "2", "yes", "", "q")
+
+	AssertOutput(t, output, "Undone: rename project 'backend' of 'coding' to 'server'")
+	activity = ReadDataFile(t)[0]
+	if activity.Projects[0].Name != "backend" || activity.Sessions[0].Project != "backend" {
+		t.Fatalf("activity %+v", activity)
+	}
+}
+
+func TestLogTime(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	output := RunScript(t, clock, "+3h", "log", "nope", "c", "25:00", "10:00", "1h30m", "", "log", "w", "30m ago", "", "", "q")
+
+	AssertOutput(t, output, "no such activity 'nope'", "use HH:MM", "Logged 1h 30m of 'coding' (02.03.2026 10:00 - 11:30)", "Logged 0h 30m of 'writing'")
+	data := ReadDataFile(t)
+	if len(data[0].Sessions) != 1 || len(data[1].Sessions) != 1 {
+		t.Fatalf("data %+v", data)
+	}
+	AssertSession(t, data[0].Sessions[0], testStart.Add(time.Hour), testStart.Add(150*time.Minute), 0, "")
+	AssertSession(t, data[1].Sessions[0], testStart.Add(150*time.Minute), testStart.Add(3*time.Hour), 0, "")
+}
+
+// Hours and minutes only, other units are refused rather than read as minutes
+func TestParseLogDuration(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   time.Duration
+		err    bool
+	}{
+		{"90", 90 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"20 minutes", 20 * time.Minute, false},
+		{"2 hours", 2 * time.Hour, false},
+		{"1.5 h", 90 * time.Minute, false},
+		{"20 s", 0, true},
+		{"20 seconds", 0, true},
+		{"20s", 0, true},
+		{"20 days", 0, true},
+		{"0", 0, true},
+	}
+
+	for _, test := range tests {
+		duration, err := ParseLogDuration(test.answer)
+		if (err != nil) != test.err || duration != test.want {
+			t.Errorf("%q: %v (%v), want %v", test.answer, duration, err, test.want)
+		}
+	}
+}
+
+func TestLogRefusesOverlap(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	output := RunScript(t, clock, "+3h", "log", "c", "10:00", "11:00", "", "log", "w", "10:30", "45", "", "log", "w", "11:30", "13:00", "", "q")
+
+	AssertOutput(t, output, "overlaps 'coding' 02.03.2026 10:00 - 11:00", "end is in the future")
+	data := ReadDataFile(t)
+	if len(data[0].Sessions) != 1 || len(data[1].Sessions) != 0 {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+/*<=================================================== Undo ===================================================>*/
+
+func TestUndoRedoPurge(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	RunScript(t, clock, "del --purge", "0", "yes", "", "undo", "", "yes", "", "q")
+
+	data := ReadDataFile(t)
+	if len(data) != 2 || data[0].Activity != "coding" || data[0].Id != 0 {
+		t.Fatalf("data %+v", data)
+	}
+
+	output := RunScript(t, clock, "redo", "", "yes", "", "q")
+
+	AssertOutput(t, output, "[1] 02.03.2026 09:00 delete 'coding'", "Redone: delete 'coding'")
+	data = ReadDataFile(t)
+	if len(data) != 1 || data[0].Activity != "writing" {
+		t.Fatalf("data %+v", data)
+	}
+}
+
+func TestUndoTaskKeepsId(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	RunScript(t, clock, "c", "a", "api", "s", "0", "a", "one", "a", "two", "d", "0", "yes", "b", "q", "no", "", "undo", "", "yes", "", "q")
+
+	tasks := ReadDataFile(t)[0].Projects[0].Tasks
+	if len(tasks) != 2 || tasks[0].Id != 0 || tasks[0].Name != "one" {
+		t.Fatalf("tasks %+v", tasks)
+	}
+}
+
+func TestUndoSavedTime(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "c", "+90m", "q", "", "undo", "", "no", "undo", "", "yes", "", "q")
+
+	AssertOutput(t, output, "Cancelled", "Undone: save 1h 30m of 'coding'")
+	if sessions := ReadDataFile(t)[0].Sessions; len(sessions) != 0 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+
+	output = RunScript(t, clock, "undo", "q")
+
+	AssertOutput(t, output, "Nothing to undo")
+}
+
+func TestUndoRefusesChanged(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	RunScript(t, clock, "c", "+30m", "q", "", "q")
+
+	// Saved time changed without the journal, other time saved since doesn't matter
+	session := ReadDataFile(t)[0].Sessions[0]
+	session.End = session.End.Add(time.Hour)
+	err := app.Store.(*JournalStore).Store.UpdateSession(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.Store.(*JournalStore).Store.AddSession(Session{ActivityId: 0, Start: session.End, End: session.End.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := RunScript(t, clock, "undo", "", "yes", "", "q")
+
+	AssertOutput(t, output, "session 0 has changed since")
+	if sessions := ReadDataFile(t)[0].Sessions; len(sessions) != 2 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+}
+
+// Entries keep what changed, not the activity with all its time
+func TestJournalKeepsOnlyChanges(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	sessions := []Session{}
+	for day := 1; day <= 500; day++ {
+		start := testStart.AddDate(0, 0, -day)
+		sessions = append(sessions, Session{ActivityId: 0, Start: start, End: start.Add(time.Hour)})
+	}
+	_, err := app.Store.(*JournalStore).Store.AddSessions(sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	RunScript(t, clock, "c", "a", "api", "r", "0", "web", "s", "0", "+30m", "q", "", "q")
+
+	journal, err := LoadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range journal.Undo {
+		if len(entry.Changes) != 1 {
+			t.Errorf("'%s' has %d changes, want 1", entry.Description, len(entry.Changes))
+		}
+	}
+	if info, err := os.Stat(journalFilename); err != nil || info.Size() > 10000 {
+		t.Fatalf("journal %+v (%v), want less than 10kB", info, err)
+	}
+
+	RunScript(t, clock, "undo", strconv.Itoa(len(journal.Undo)), "yes", "", "q")
+
+	activity := ReadDataFile(t)[0]
+	if len(activity.Projects) != 0 || len(activity.Sessions) != 500 {
+		t.Fatalf("projects %+v, %d sessions", activity.Projects, len(activity.Sessions))
+	}
+}
+
+/*<=================================================== Recover ===================================================>*/
+
+func TestRecoverSave(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+	clock.now = testStart.Add(3 * time.Hour)
+
+	err := SaveTimer(Timer{ActivityId: 0, Activity: "coding", Start: testStart})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := RunScript(t, clock, "s", "25:00", "10:30", "q")
+
+	AssertOutput(t, output, "Unfinished timer: [coding]", "LAST TIME HAS BEEN SAVED")
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 1 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart, testStart.Add(90*time.Minute), 0, "")
+	AssertNoTimer(t)
+}
+
+func TestRecoverResume(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+	clock.now = testStart.Add(time.Hour)
+
+	// Paused 20 minutes before the crash, still paused
+	pausedAt := testStart.Add(40 * time.Minute)
+	err := SaveTimer(Timer{ActivityId: 0, Activity: "coding", Start: testStart, PauseSeconds: 300, PausedAt: &pausedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	RunScript(t, clock, "r", "+30m", "q", "", "q")
+
+	sessions := ReadDataFile(t)[0].Sessions
+	if len(sessions) != 1 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+	AssertSession(t, sessions[0], testStart, testStart.Add(90*time.Minute), 25, "")
+}
+
+func TestInputEndKeepsTimer(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	RunScript(t, clock, "c", "+5m")
+
+	timer, err := LoadTimer()
+	if err != nil || timer == nil || timer.Activity != "coding" || !timer.Start.Equal(testStart) {
+		t.Fatalf("timer %+v (%v)", timer, err)
+	}
+	if sessions := ReadDataFile(t)[0].Sessions; len(sessions) != 0 {
+		t.Fatalf("sessions %+v", sessions)
+	}
+}
+
+// Input ending inside a question stops the commandline, nothing half asked is saved
+func TestInputEndInQuestions(t *testing.T) {
+	for _, script := range [][]string{
+		{"a", "coding"},
+		{"a", "writing"},
+		{"log", "c", "x"},
+		{"c", "a"},
+		{"c", "+5m", "q"},
+	} {
+		clock := NewTestApp(t, "coding c")
+		RunScript(t, clock, script...)
+
+		data := ReadDataFile(t)
+		if len(data) != 1 || len(data[0].Projects) != 0 || len(data[0].Sessions) != 0 {
+			t.Errorf("%v: data %+v", script, data)
+		}
+	}
+}
+
+/*<=================================================== Print ===================================================>*/
+
+func TestTopAndReport(t *testing.T) {
+	clock := NewTestApp(t, "coding c", "writing w")
+
+	RunScript(t, clock, "c", "+2h", "q", "", "w", "+30m", "q", "", "q")
+	output := RunScript(t, clock, "t", "", "report", "", "q")
+
+	AssertOutput(t, output, "[1 Place] coding (2 hours 0 minutes)", "[2 Place] writing (0 hours 30 minutes)",
+		"(total 2h 30m)", "[coding] 2h 00m (80.0%)")
+}
+
+// Sessions count on the day they started where the dashboard is looked at
+func TestDashboardDaysInLocation(t *testing.T) {
+	NewTestApp(t)
+
+	east := time.FixedZone("east", 2*60*60)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, east)
+	start := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	data := []JsonData{{Activity: "coding", Sessions: []Session{{Start: start, End: start.Add(time.Hour)}}}}
+
+	days := BuildDashboard(data, 2, now).Days
+	if len(days) != 2 || days[0].Minutes != 0 || days[1].Minutes != 60 {
+		t.Fatalf("days %+v", days)
+	}
+}
+
+func TestOutputHasNoColors(t *testing.T) {
+	clock := NewTestApp(t, "coding c")
+
+	output := RunScript(t, clock, "q")
+
+	if strings.Contains(output, "\x1b[") {
+		t.Errorf("output has escape codes: %q", output)
+	}
+}
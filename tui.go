@@ -0,0 +1,1056 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TwiN/go-color"
+	"golang.org/x/term"
+)
+
+// Panes of the full screen ui, from left to right
+const (
+	paneActivities = iota
+	paneProjects
+	paneTasks
+)
+
+var paneTitles = []string{"Activities", "Projects", "Tasks"}
+
+// State of the full screen ui
+type Tui struct {
+	// All activities and the ones shown in the panes, without archived activities and projects
+	data  []JsonData
+	shown []JsonData
+
+	timer  *Timer
+	config Config
+	focus  int
+	cursor [3]int
+
+	// Message in the status bar
+	status    string
+	statusRed bool
+
+	// Question in the status bar, keys go to it while it is open
+	prompt *TuiPrompt
+
+	// Report or top list shown instead of the panes until a key is pressed
+	view []string
+
+	quit bool
+}
+
+// Question asked in the status bar. With Choices one key answers,
+// without them a line is typed and Enter submits it.
+type TuiPrompt struct {
+	Label   string
+	Choices string
+	Input   string
+	Submit  func(answer string) error
+}
+
+// Start full screen ui, terminal is restored when it ends
+func RunTui() error {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	// Alternate screen, hidden cursor
+	app.Out.Print("\x1b[?1049h\x1b[?25l")
+	defer app.Out.Print("\x1b[?25h\x1b[?1049l")
+
+	t := &Tui{}
+	t.Reload()
+
+	keys := make(chan string)
+	go ReadKeys(keys)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for ticks := 0; !t.quit; {
+		t.Draw()
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			t.HandleKey(key)
+		case <-ticker.C:
+			// Timer or data may be changed by tm start / stop in another terminal
+			ticks++
+			t.CheckPomodoro()
+			if ticks%5 == 0 {
+				t.Reload()
+			} else {
+				t.ReloadTimer()
+			}
+		}
+	}
+	return nil
+}
+
+// Send key names to channel: up, down, left, right, enter, esc, tab, backspace, ctrl-c or the typed letter
+func ReadKeys(keys chan<- string) {
+	buffer := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			close(keys)
+			return
+		}
+
+		for _, key := range ParseKeys(buffer[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// Split one read from the terminal to keys
+func ParseKeys(input []byte) []string {
+	arrows := map[byte]string{'A': "up", 'B': "down", 'C': "right", 'D': "left"}
+
+	keys := []string{}
+	text := []rune(string(input))
+	for i := 0; i < len(text); i++ {
+		switch r := text[i]; {
+		case r == 0x1b && i+2 < len(text) && (text[i+1] == '[' || text[i+1] == 'O'):
+			// Arrow keys are ESC [ A, other escape sequences are skipped
+			if name, ok := arrows[byte(text[i+2])]; ok {
+				keys = append(keys, name)
+			}
+			i += 2
+		case r == 0x1b:
+			keys = append(keys, "esc")
+		case r == '\r' || r == '\n':
+			keys = append(keys, "enter")
+		case r == '\t':
+			keys = append(keys, "tab")
+		case r == 0x7f || r == 0x08:
+			keys = append(keys, "backspace")
+		case r == 0x03:
+			keys = append(keys, "ctrl-c")
+		case r >= ' ':
+			keys = append(keys, string(r))
+		}
+	}
+	return keys
+}
+
+/*<=================================================== State functions ===================================================>*/
+
+// Get activities and timer again
+func (t *Tui) Reload() {
+	data, err := app.Store.Activities()
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+		return
+	}
+	t.data = data
+	t.shown = VisibleActivities(data)
+	t.ReloadTimer()
+	t.ClampCursors()
+
+	t.config, err = LoadConfig()
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+	}
+}
+
+func (t *Tui) ReloadTimer() {
+	timer, err := LoadTimer()
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+		return
+	}
+	t.timer = timer
+}
+
+// Keep cursors inside the lists after something is deleted
+func (t *Tui) ClampCursors() {
+	lengths := []int{len(t.shown), len(t.Projects()), len(t.Tasks())}
+	for pane, length := range lengths {
+		if t.cursor[pane] >= length {
+			t.cursor[pane] = length - 1
+		}
+		if t.cursor[pane] < 0 {
+			t.cursor[pane] = 0
+		}
+	}
+}
+
+func (t *Tui) SetStatus(message string, red bool) {
+	t.status = message
+	t.statusRed = red
+}
+
+// Activity under the cursor
+func (t *Tui) Activity() (JsonData, bool) {
+	if len(t.shown) == 0 {
+		return JsonData{}, false
+	}
+	return t.shown[t.cursor[paneActivities]], true
+}
+
+// Projects of the activity under the cursor
+func (t *Tui) Projects() []Project {
+	activity, ok := t.Activity()
+	if !ok {
+		return nil
+	}
+	return activity.Projects
+}
+
+// Tasks of the project under the cursor
+func (t *Tui) Tasks() []Task {
+	projects := t.Projects()
+	if len(projects) == 0 || t.cursor[paneProjects] >= len(projects) {
+		return nil
+	}
+	return projects[t.cursor[paneProjects]].Tasks
+}
+
+// Timer runs for the activity under the cursor
+func (t *Tui) TimerOnActivity() bool {
+	activity, ok := t.Activity()
+	return ok && t.timer != nil && t.timer.ActivityId == activity.Id
+}
+
+/*<=================================================== Key functions ===================================================>*/
+
+func (t *Tui) HandleKey(key string) {
+	if key == "ctrl-c" {
+		t.quit = true
+		return
+	}
+
+	if t.prompt != nil {
+		t.PromptKey(key)
+		return
+	}
+
+	// Any key closes report and top list
+	if t.view != nil {
+		t.view = nil
+		return
+	}
+
+	t.SetStatus("", false)
+
+	switch key {
+	case "up", "k":
+		t.MoveCursor(-1)
+	case "down", "j":
+		t.MoveCursor(1)
+	case "left", "h":
+		t.MoveFocus(-1)
+	case "right", "l", "tab":
+		t.MoveFocus(1)
+	case "enter", "s":
+		t.Select()
+	case "p", "+":
+		t.TogglePause()
+	case "P":
+		t.TogglePomodoro()
+	case "x":
+		t.Stop()
+	case "a":
+		t.Add()
+	case "d":
+		t.Delete()
+	case "e":
+		t.Edit()
+	case "o":
+		t.ToggleDone()
+	case "!":
+		t.CyclePriority()
+	case "u":
+		t.Replay(true)
+	case "U":
+		t.Replay(false)
+	case "r":
+		t.ShowReport()
+	case "t":
+		t.ShowTop()
+	case "q", "esc":
+		t.Quit()
+	}
+}
+
+// Type into prompt or answer its choice
+func (t *Tui) PromptKey(key string) {
+	prompt := t.prompt
+
+	switch {
+	case key == "esc":
+		t.prompt = nil
+		return
+	case prompt.Choices != "":
+		if len(key) != 1 || !strings.Contains(prompt.Choices, key) {
+			return
+		}
+		prompt.Input = key
+	case key == "backspace":
+		if input := []rune(prompt.Input); len(input) > 0 {
+			prompt.Input = string(input[:len(input)-1])
+		}
+		return
+	case key != "enter":
+		if len([]rune(key)) == 1 {
+			prompt.Input += key
+		}
+		return
+	}
+
+	// Submit may open the next prompt, on error the same prompt stays open
+	t.prompt = nil
+	err := prompt.Submit(strings.TrimSpace(prompt.Input))
+	if err != nil {
+		prompt.Input = ""
+		t.prompt = prompt
+		t.SetStatus(err.Error(), true)
+	}
+	t.Reload()
+}
+
+func (t *Tui) MoveCursor(step int) {
+	t.cursor[t.focus] += step
+
+	// Projects and tasks below belong to another activity or project now
+	for pane := t.focus + 1; pane <= paneTasks; pane++ {
+		t.cursor[pane] = 0
+	}
+	t.ClampCursors()
+}
+
+func (t *Tui) MoveFocus(step int) {
+	t.focus += step
+	if t.focus < paneActivities {
+		t.focus = paneActivities
+	}
+	if t.focus > paneTasks {
+		t.focus = paneTasks
+	}
+}
+
+// Start activity, or pick project and task for the running timer
+func (t *Tui) Select() {
+	activity, ok := t.Activity()
+	if !ok {
+		t.SetStatus("No activities, add one with a", true)
+		return
+	}
+
+	if t.focus == paneActivities {
+		t.Start(activity)
+		return
+	}
+
+	if !t.TimerOnActivity() {
+		t.SetStatus("Start '"+activity.Activity+"' first", true)
+		return
+	}
+
+	project, task := "", ""
+	if projects := t.Projects(); len(projects) > 0 {
+		project = projects[t.cursor[paneProjects]].Name
+	}
+	if tasks := t.Tasks(); t.focus == paneTasks && len(tasks) > 0 {
+		selected := tasks[t.cursor[paneTasks]]
+		if selected.Status == TaskDone {
+			t.SetStatus("'"+selected.Name+"' is done, reopen it with o", true)
+			return
+		}
+		task = selected.Name
+
+		// Task the timer runs for is being done
+		if t.timer.Project != project || t.timer.Task != task {
+			if err := MarkTaskDoing(selected); err != nil {
+				t.SetStatus(err.Error(), true)
+			}
+		}
+	}
+
+	// Selecting the same project again goes back to no project
+	if t.timer.Project == project && t.timer.Task == task {
+		project, task = "", ""
+	}
+
+	// Time so far goes to the project and task before
+	_, session, err := SwitchTimerTarget(project, task, app.Now())
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+	} else if session != nil {
+		name := strings.Join(NonEmpty(t.timer.Activity, session.Project, session.Task), " / ")
+		t.SetStatus("Saved "+FormatMinutes(SessionMinutes(*session))+" for '"+name+"'", false)
+	}
+	t.Reload()
+}
+
+func (t *Tui) Start(activity JsonData) {
+	if t.timer != nil {
+		t.SetStatus("'"+t.timer.Activity+"' is already running, stop it first with x", true)
+		return
+	}
+
+	err := WithTimerLock(func() error {
+		_, err := BeginTimer(activity.Activity, "", "", app.Now())
+		return err
+	})
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+	} else {
+		t.SetStatus("Started '"+activity.Activity+"', pick a project with → and Enter", false)
+	}
+	t.ReloadTimer()
+}
+
+func (t *Tui) TogglePause() {
+	if t.timer == nil {
+		t.SetStatus(ErrNoTimer.Error(), true)
+		return
+	}
+
+	var err error
+	if t.timer.PausedAt == nil {
+		_, err = PauseTimer(app.Now())
+	} else {
+		_, err = ResumeTimer(app.Now())
+	}
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+	}
+	t.ReloadTimer()
+}
+
+// Turn pomodoro mode of the running timer on or off
+func (t *Tui) TogglePomodoro() {
+	timer, err := TogglePomodoro(app.Now())
+	switch {
+	case err != nil:
+		t.SetStatus(err.Error(), true)
+	case timer.Pomodoro == nil:
+		t.SetStatus("Pomodoro mode off", false)
+	default:
+		t.SetStatus("Pomodoro mode on, "+PomodoroStatus(*timer.Pomodoro), false)
+	}
+	t.ReloadTimer()
+}
+
+// Catch up with pomodoro phases, the last one that began is shown with the bell
+func (t *Tui) CheckPomodoro() {
+	_, transitions, err := AdvancePomodoro(app.Now())
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+		return
+	}
+	if len(transitions) == 0 {
+		return
+	}
+
+	app.Out.Bell()
+	t.SetStatus(PomodoroNotice(transitions[len(transitions)-1]), false)
+	t.Reload()
+}
+
+// Ask to save the time, like quitting an activity in the commandline
+func (t *Tui) Stop() {
+	if t.timer == nil {
+		t.SetStatus(ErrNoTimer.Error(), true)
+		return
+	}
+
+	t.prompt = &TuiPrompt{
+		Label:   "Save the time of '" + t.timer.Activity + "'? (y)es (n)o, esc: keep running",
+		Choices: "yn",
+		Submit: func(answer string) error {
+			return t.EndTimer(answer == "n")
+		},
+	}
+}
+
+func (t *Tui) EndTimer(discard bool) error {
+	var session *Session
+	err := WithTimerLock(func() error {
+		var err error
+		_, session, err = EndTimer(discard, app.Now())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if session == nil {
+		t.SetStatus("LAST TIME NOT SAVED", true)
+	} else {
+		t.SetStatus("LAST TIME HAS BEEN SAVED ("+FormatMinutes(SessionMinutes(*session))+")", false)
+	}
+	return nil
+}
+
+// Quit ui, a running timer can be saved, discarded or kept
+func (t *Tui) Quit() {
+	if t.timer == nil {
+		t.quit = true
+		return
+	}
+
+	t.prompt = &TuiPrompt{
+		Label:   "'" + t.timer.Activity + "' is running: (s)ave (d)iscard (k)eep running, esc: cancel",
+		Choices: "sdk",
+		Submit: func(answer string) error {
+			if answer != "k" {
+				err := t.EndTimer(answer == "d")
+				if err != nil {
+					return err
+				}
+			}
+			t.quit = true
+			return nil
+		},
+	}
+}
+
+// Add activity, project or task depending on the focused pane
+func (t *Tui) Add() {
+	activity, ok := t.Activity()
+
+	switch {
+	case t.focus == paneActivities:
+		t.prompt = &TuiPrompt{Label: "Activity name?", Submit: func(name string) error {
+			err := CheckActivityName(name, t.data)
+			if err != nil {
+				return err
+			}
+
+			t.prompt = &TuiPrompt{Label: "Short name?", Submit: func(short string) error {
+				err := CheckActivityName(short, t.data)
+				if err != nil {
+					return err
+				}
+
+				_, err = app.Store.AddActivity(ConvertAnswersToJsonData(name, short))
+				if err == nil {
+					t.SetStatus("Activity '"+name+"' added to db!", false)
+				}
+				return err
+			}}
+			return nil
+		}}
+
+	case !ok:
+		t.SetStatus("No activities, add one first", true)
+
+	case t.focus == paneProjects:
+		t.prompt = &TuiPrompt{Label: "Project name?", Submit: func(name string) error {
+			err := CheckProjectName(name, t.data)
+			if err != nil {
+				return err
+			}
+
+			_, err = app.Store.AddProject(activity.Id, Project{Name: name, Tasks: []Task{}})
+			if err == nil {
+				t.SetStatus("Project '"+name+"' added to db!", false)
+			}
+			return err
+		}}
+
+	case len(t.Projects()) == 0:
+		t.SetStatus("No projects, add one first", true)
+
+	default:
+		project := t.Projects()[t.cursor[paneProjects]]
+		t.prompt = &TuiPrompt{Label: "Task name?", Submit: func(name string) error {
+			if name == "" {
+				return errors.New("task name can't be empty")
+			}
+
+			_, err := app.Store.AddTask(project.Id, NewTask(name))
+			if err == nil {
+				t.SetStatus("Task '"+name+"' added to project '"+project.Name+"'!", false)
+			}
+			return err
+		}}
+	}
+}
+
+// Archive activity or project, or delete task under the cursor after asking
+func (t *Tui) Delete() {
+	activity, ok := t.Activity()
+	if !ok {
+		return
+	}
+
+	name, done := "", "archived! tm unarchive restores it"
+	var remove func() error
+
+	switch t.focus {
+	case paneActivities:
+		if t.TimerOnActivity() {
+			t.SetStatus("'"+activity.Activity+"' is running, stop it first with x", true)
+			return
+		}
+		name = activity.Activity
+		remove = func() error { return app.Store.ArchiveActivity(activity.Id, true) }
+	case paneProjects:
+		if len(t.Projects()) == 0 {
+			return
+		}
+		project := t.Projects()[t.cursor[paneProjects]]
+		name = project.Name
+		remove = func() error { return app.Store.ArchiveProject(project.Id, true) }
+	case paneTasks:
+		if len(t.Tasks()) == 0 {
+			return
+		}
+		task := t.Tasks()[t.cursor[paneTasks]]
+		name, done = task.Name, "has been deleted!"
+		remove = func() error { return app.Store.DeleteTask(task.Id) }
+	}
+
+	t.prompt = &TuiPrompt{
+		Label:   "Remove '" + name + "'? (y)es (n)o",
+		Choices: "yn",
+		Submit: func(answer string) error {
+			if answer == "n" {
+				return nil
+			}
+
+			err := remove()
+			if err == nil {
+				t.SetStatus("'"+name+"' "+done, true)
+			}
+			return err
+		},
+	}
+}
+
+// Mark task under the cursor done, or reopen it
+func (t *Tui) ToggleDone() {
+	if t.focus != paneTasks || len(t.Tasks()) == 0 {
+		t.SetStatus("Pick a task with → first", true)
+		return
+	}
+	task := t.Tasks()[t.cursor[paneTasks]]
+
+	status, message := TaskDone, "' done"
+	if task.Status == TaskDone {
+		status, message = TaskTodo, "' reopened"
+	}
+	err := SetTaskStatus(task, status)
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+	} else {
+		t.SetStatus("Task '"+task.Name+message, false)
+	}
+	t.Reload()
+}
+
+// Next priority for the task under the cursor, high goes back to none
+func (t *Tui) CyclePriority() {
+	if t.focus != paneTasks || len(t.Tasks()) == 0 {
+		t.SetStatus("Pick a task with → first", true)
+		return
+	}
+	task := t.Tasks()[t.cursor[paneTasks]]
+
+	task.Priority = (task.Priority + 1) % len(priorityNames)
+	err := app.Store.UpdateTask(task)
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+	} else {
+		t.SetStatus("Task '"+task.Name+"' priority: "+PriorityName(task.Priority), false)
+	}
+	t.Reload()
+}
+
+// Task in the pane: [ ] todo, [>] doing, [x] done, one ! for each priority level
+func TaskLabel(task Task) string {
+	box := map[string]string{TaskDoing: "[>]", TaskDone: "[x]"}[task.Status]
+	if box == "" {
+		box = "[ ]"
+	}
+
+	label := box + " " + task.Name
+	if task.Priority > 0 {
+		label += " " + strings.Repeat("!", task.Priority)
+	}
+	if TaskOverdue(task, app.Now()) {
+		label += " overdue"
+	}
+	return label
+}
+
+// Rename activity, project or task under the cursor, empty answer keeps the name
+func (t *Tui) Edit() {
+	activity, ok := t.Activity()
+	if !ok {
+		return
+	}
+
+	switch t.focus {
+	case paneActivities:
+		t.prompt = &TuiPrompt{Label: "Activity name? (empty keeps '" + activity.Activity + "')", Submit: func(name string) error {
+			name = Keep(name, activity.Activity)
+			err := CheckActivityRename(activity, name, t.data)
+			if err != nil {
+				return err
+			}
+
+			t.prompt = &TuiPrompt{Label: "Short name? (empty keeps '" + activity.Short + "')", Submit: func(short string) error {
+				short = Keep(short, activity.Short)
+				err := CheckActivityRename(activity, short, t.data)
+				if err != nil {
+					return err
+				}
+
+				err = RenameActivity(activity, name, short)
+				if err == nil {
+					t.SetStatus("'"+activity.Activity+"' renamed to '"+name+"'", false)
+				}
+				return err
+			}}
+			return nil
+		}}
+
+	case paneProjects:
+		if len(t.Projects()) == 0 {
+			return
+		}
+		project := t.Projects()[t.cursor[paneProjects]]
+		t.prompt = &TuiPrompt{Label: "Project name? (empty keeps '" + project.Name + "')", Submit: func(name string) error {
+			name = Keep(name, project.Name)
+			err := CheckProjectRename(project, name, t.data)
+			if err != nil {
+				return err
+			}
+
+			err = RenameProject(activity, project, name)
+			if err == nil {
+				t.SetStatus("Project '"+project.Name+"' renamed to '"+name+"'", false)
+			}
+			return err
+		}}
+
+	case paneTasks:
+		if len(t.Tasks()) == 0 {
+			return
+		}
+		project := t.Projects()[t.cursor[paneProjects]]
+		task := t.Tasks()[t.cursor[paneTasks]]
+		t.prompt = &TuiPrompt{Label: "Task name? (empty keeps '" + task.Name + "')", Submit: func(name string) error {
+			name = Keep(name, task.Name)
+			err := CheckTaskRename(task, project, name)
+			if err != nil {
+				return err
+			}
+
+			err = RenameTask(activity, project, task, name)
+			if err == nil {
+				t.SetStatus("Task '"+task.Name+"' renamed to '"+name+"'", false)
+			}
+			return err
+		}}
+	}
+}
+
+// Answer or the current value when nothing was typed
+func Keep(answer string, current string) string {
+	if answer == "" {
+		return current
+	}
+	return answer
+}
+
+// Undo or redo the last operation after asking
+func (t *Tui) Replay(undo bool) {
+	verb := "Redo"
+	if undo {
+		verb = "Undo"
+	}
+
+	journal, err := LoadJournal()
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+		return
+	}
+	entries := journal.Redo
+	if undo {
+		entries = journal.Undo
+	}
+
+	last := LastEntries(entries, 1)
+	if len(last) == 0 {
+		t.SetStatus("Nothing to "+strings.ToLower(verb), true)
+		return
+	}
+
+	t.prompt = &TuiPrompt{
+		Label:   verb + " '" + last[0].Description + "'? (y)es (n)o",
+		Choices: "yn",
+		Submit: func(answer string) error {
+			if answer == "n" {
+				return nil
+			}
+
+			_, err := ReplayJournal(1, undo)
+			if err == nil {
+				t.SetStatus(verb+" '"+last[0].Description+"' done", false)
+			}
+			return err
+		},
+	}
+}
+
+// This week's report instead of the panes
+func (t *Tui) ShowReport() {
+	from, to, err := ReportRange("week", "", "", app.Now())
+	if err != nil {
+		t.SetStatus(err.Error(), true)
+		return
+	}
+	report := BuildReport(t.data, from, to)
+
+	t.view = []string{fmt.Sprintf("Report %s - %s (total %s)", report.From.Format("02.01.2006"),
+		report.To.AddDate(0, 0, -1).Format("02.01.2006"), FormatMinutes(report.Minutes)), ""}
+
+	if len(report.Activities) == 0 {
+		t.view = append(t.view, "No time saved in this period")
+	}
+	for _, activity := range report.Activities {
+		t.view = append(t.view, ReportLine(activity, report.Minutes, ""))
+		for _, project := range activity.Items {
+			t.view = append(t.view, ReportLine(project, report.Minutes, "    "))
+			for _, task := range project.Items {
+				t.view = append(t.view, ReportLine(task, report.Minutes, "        "))
+			}
+		}
+	}
+}
+
+func ReportLine(item ReportItem, total int, indent string) string {
+	return fmt.Sprintf("%s[%s] %s (%s)", indent, item.Name, FormatMinutes(item.Minutes), Percent(item.Minutes, total))
+}
+
+// Top 5 activities instead of the panes
+func (t *Tui) ShowTop() {
+	top := append([]JsonData{}, t.data...)
+	sort.SliceStable(top, func(i, j int) bool {
+		return ActivityDuration(top[i]) > ActivityDuration(top[j])
+	})
+	if len(top) > 5 {
+		top = top[:5]
+	}
+
+	t.view = []string{"Top activities", ""}
+	for place, activity := range top {
+		t.view = append(t.view, fmt.Sprintf("%d. %s %s", place+1, activity.Activity, FormatMinutes(ActivityMinutes(activity))))
+	}
+}
+
+/*<=================================================== Draw functions ===================================================>*/
+
+// Draw whole screen at once
+func (t *Tui) Draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 6 {
+		width, height = 80, 24
+	}
+
+	lines := []string{t.HeaderLine(width), t.TimerLine(width), ""}
+
+	body := height - len(lines) - 1
+	if t.view != nil {
+		for i := 0; i < body; i++ {
+			text := ""
+			if i < len(t.view) {
+				text = " " + t.view[i]
+			}
+			lines = append(lines, Fit(text, width))
+		}
+	} else {
+		lines = append(lines, t.PaneLines(width, body)...)
+	}
+
+	lines = append(lines, t.StatusLine(width))
+
+	var screen bytes.Buffer
+	screen.WriteString("\x1b[H")
+	screen.WriteString(strings.Join(lines, "\r\n"))
+	app.Out.Print(screen.String())
+}
+
+func (t *Tui) HeaderLine(width int) string {
+	spent := time.Duration(0)
+	for _, activity := range t.data {
+		spent += ActivityDuration(activity)
+	}
+	minutes := app.Rounding.Round(spent)
+	text := fmt.Sprintf(" VK TimeManager v%s (%d hours)   ", ProgramVersion, minutes/60)
+
+	status := t.config.DayStatus(t.data, t.timer, app.Now())
+	if status.Off {
+		text += "Day off"
+	} else {
+		text += FormatMinutes(status.Left) + " left till " + status.End.Format("15:04")
+	}
+	text += "   today " + FormatMinutes(status.Logged)
+	if status.Working {
+		text += "   capacity " + FormatMinutes(status.Capacity)
+	}
+	return app.Out.Colorize(color.Green, Fit(text, width))
+}
+
+// Live ticking timer
+func (t *Tui) TimerLine(width int) string {
+	if t.timer == nil {
+		return app.Out.Colorize(color.Gray, Fit(" No timer running, start an activity with Enter", width))
+	}
+
+	name := strings.Join(NonEmpty(t.timer.Activity, t.timer.Project, t.timer.Task), " / ")
+	text := fmt.Sprintf(" ▶ %s   %s   since %s", name, FormatElapsed(TimerElapsed(*t.timer, app.Now())), t.timer.Start.Format("15:04:05"))
+	if pomodoro := t.timer.Pomodoro; pomodoro != nil {
+		text += "   pomodoro " + PomodoroStatus(*pomodoro)
+		if pomodoro.Phase != PhaseWork {
+			return app.Out.Colorize(color.Cyan, Fit(text, width))
+		}
+	}
+	if t.timer.PausedAt != nil {
+		return app.Out.Colorize(color.Yellow, Fit(text+"   PAUSED", width))
+	}
+	return app.Out.Colorize(color.Bold, Fit(text, width))
+}
+
+// Activities, projects and tasks side by side
+func (t *Tui) PaneLines(width int, height int) []string {
+	columns := [3][]string{}
+	for _, activity := range t.shown {
+		mark := " "
+		if t.timer != nil && t.timer.ActivityId == activity.Id {
+			mark = "▶"
+		}
+		columns[paneActivities] = append(columns[paneActivities],
+			fmt.Sprintf("%s %s [%s] %s", mark, activity.Activity, activity.Short, FormatMinutes(ActivityMinutes(activity))))
+	}
+
+	// Projects and tasks with the time spent on them
+	running := t.TimerOnActivity()
+	activity, _ := t.Activity()
+	for _, project := range t.Projects() {
+		mark := " "
+		if running && t.timer.Project == project.Name {
+			mark = "▶"
+		}
+		columns[paneProjects] = append(columns[paneProjects],
+			fmt.Sprintf("%s %s %s", mark, project.Name, FormatMinutes(app.Rounding.Round(ProjectDuration(activity, project.Id)))))
+	}
+
+	selectedProject := ""
+	if projects := t.Projects(); len(projects) > 0 {
+		selectedProject = projects[t.cursor[paneProjects]].Name
+	}
+	for _, task := range t.Tasks() {
+		mark := " "
+		if running && t.timer.Project == selectedProject && t.timer.Task == task.Name {
+			mark = "▶"
+		}
+		columns[paneTasks] = append(columns[paneTasks],
+			fmt.Sprintf("%s %s %s", mark, TaskLabel(task), FormatMinutes(app.Rounding.Round(TaskDuration(activity, task.Id)))))
+	}
+
+	paneWidth := width / 3
+	lines := []string{}
+	for row := -1; row < height; row++ {
+		line := ""
+		for pane, rows := range columns {
+			cellWidth := paneWidth
+			if pane == paneTasks {
+				cellWidth = width - 2*paneWidth
+			}
+
+			switch {
+			case row == -1:
+				// Pane title
+				style := color.Gray
+				if pane == t.focus {
+					style = color.Green + color.Bold
+				}
+				line += app.Out.Colorize(style, Fit(" "+paneTitles[pane], cellWidth))
+			case row < len(rows) && row == t.cursor[pane] && pane == t.focus:
+				line += "\x1b[7m" + Fit(rows[row], cellWidth) + color.Reset
+			case row < len(rows) && row == t.cursor[pane]:
+				line += app.Out.Colorize(color.White+color.Bold, Fit(rows[row], cellWidth))
+			case row < len(rows):
+				line += Fit(rows[row], cellWidth)
+			default:
+				line += Fit("", cellWidth)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Prompt, message or key help
+func (t *Tui) StatusLine(width int) string {
+	if t.prompt != nil {
+		text := " " + t.prompt.Label + " "
+		if t.prompt.Choices == "" {
+			text += "=> " + t.prompt.Input + "█"
+		}
+		return "\x1b[7m" + Fit(text, width) + color.Reset
+	}
+
+	if t.status != "" {
+		style := color.Green
+		if t.statusRed {
+			style = color.Red
+		}
+		return app.Out.Colorize(style, Fit(" "+t.status, width))
+	}
+
+	help := " ↑↓ move  ←→ pane  Enter start/select  p pause  P pomodoro  x stop  a add  e rename  d archive  o done  ! prio  u/U undo/redo  r report  t top  q quit"
+	return "\x1b[7m" + Fit(help, width) + color.Reset
+}
+
+/*<=================================================== Small Help functions ===================================================>*/
+
+// Cut or pad text to width
+func Fit(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		if width < 1 {
+			return ""
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-len(runes))
+}
+
+// Duration as 1:02:03
+func FormatElapsed(elapsed time.Duration) string {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	seconds := int(elapsed.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+func NonEmpty(texts ...string) []string {
+	result := []string{}
+	for _, text := range texts {
+		if text != "" {
+			result = append(result, text)
+		}
+	}
+	return result
+}